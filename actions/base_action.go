@@ -5,7 +5,7 @@ import "github.com/lighmon-even/filetool/base"
 type FileManager = base.FileManager
 
 type data struct {
-	FileManagers map[string]FileManager
+	FileManagers map[string]*FileManager
 }
 
 type FileRequest interface {
@@ -49,7 +49,7 @@ func NewBaseFileResponse(currentWorkingDirectory string) *BaseFileResponse {
 
 type FileAction interface {
 	base.Action
-	ExecuteOnFileManager(fileManager FileManager, requestData BaseFileRequest) BaseFileResponse
+	ExecuteOnFileManager(fileManager *FileManager, requestData BaseFileRequest) BaseFileResponse
 }
 
 type BaseFileAction struct {
@@ -60,7 +60,7 @@ func NewBaseFileAction() *BaseFileAction {
 	return &BaseFileAction{}
 }
 
-func (s *BaseFileAction) ExecuteOnFileManager(fileManager FileManager, requestData FileRequest) (map[string]any, FileResponse) {
+func (s *BaseFileAction) ExecuteOnFileManager(fileManager *FileManager, requestData FileRequest) (map[string]any, FileResponse) {
 	return nil, NewBaseFileResponse("")
 }
 
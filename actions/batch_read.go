@@ -0,0 +1,159 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/lighmon-even/filetool/base"
+)
+
+// batchReadSmallFile is the largest file, in lines, that an entry without a
+// range reads whole; larger files show their first batchReadSmallFile lines.
+const batchReadSmallFile = 200
+
+// batchReadDefaultTokens is the shared budget when the request sets none.
+const batchReadDefaultTokens = 8000
+
+type BatchReadEntry struct {
+	FilePath string
+	//"Path of the file to read.",
+	StartLine int
+	//"First line to read. Leave StartLine and EndLine at 0 to read the whole "
+	//"file if it is small, or its beginning otherwise.",
+	EndLine int
+	//"Last line to read (inclusive). 0 reads to the end of the file.",
+}
+
+type BatchReadRequest struct {
+	*BaseFileRequest
+	//"""Request to read several files or parts of files at once."""
+	Entries []BatchReadEntry
+	//"The files and line ranges to read, in the order they are returned.",
+	MaxTokens int
+	//"Roughly how many tokens all entries together may use. Entries are "
+	//"read in order until it runs out.",
+}
+
+type BatchReadResult struct {
+	FilePath string
+	//"Path of the file as requested.",
+	Lines map[int]string
+	//"The lines read, by line number. For binary files, a hex dump by byte offset.",
+	StartLine int
+	//"First line returned.",
+	EndLine int
+	//"Last line returned.",
+	TotalLines int
+	//"Number of lines in the file; 0 for binary files.",
+	Note string
+	//"Why fewer lines than requested were returned, if they were.",
+	Error error
+	//"Why the entry could not be read.",
+}
+
+type BatchReadResponse struct {
+	*BaseFileResponse
+	//"""Response to a batch read."""
+	Results []BatchReadResult
+	//"One result per entry, in the order of the request.",
+}
+
+func NewBatchReadResponse() *BatchReadResponse {
+	return &BatchReadResponse{
+		NewBaseFileResponse(""),
+		nil,
+	}
+}
+
+type BatchRead struct {
+	*BaseFileAction
+	//"""
+	//Use this tool to read several files, or several ranges of files, in one
+	//step instead of opening and scrolling them one by one.
+	//
+	//All entries share one token budget. When it runs out, the entry being
+	//read is cut short and the remaining entries are skipped; each result
+	//notes what was left out, so you can ask for it again.
+	//"""
+	displayName    string             // = "Read several files"
+	requestSchema  *BatchReadRequest  // = BatchReadRequest
+	responseSchema *BatchReadResponse //= BatchReadResponse
+}
+
+func NewBatchRead() *BatchRead {
+	return &BatchRead{
+		displayName: "Read several files",
+	}
+}
+
+func (br *BatchRead) ExecuteOnFileManager(
+	fileManager *FileManager,
+	requestData BatchReadRequest,
+) (brr *BatchReadResponse) {
+	brr = NewBatchReadResponse()
+	tokens := requestData.MaxTokens
+	if tokens <= 0 {
+		tokens = batchReadDefaultTokens
+	}
+	remaining := tokens * base.CharsPerToken
+	for _, entry := range requestData.Entries {
+		result := BatchReadResult{FilePath: entry.FilePath}
+		brr.Results = append(brr.Results, result)
+		current := &brr.Results[len(brr.Results)-1]
+		if remaining <= 0 {
+			current.Note = "skipped: the token budget ran out"
+			continue
+		}
+		file, err := fileManager.Lookup(entry.FilePath)
+		if err != nil {
+			current.Error = err
+			continue
+		}
+		binary, _, err := file.IsBinary()
+		if err != nil {
+			current.Error = err
+			continue
+		}
+
+		start, end := entry.StartLine, entry.EndLine
+		if start <= 0 {
+			start = 1
+		}
+		if binary {
+			// Lines of a binary file mean nothing, so its range counts rows
+			// of the hex dump and it is not given a line count.
+			if end <= 0 {
+				end = start - 1 + batchReadSmallFile
+			}
+		} else if current.TotalLines = file.TotalLines(); end <= 0 {
+			end = current.TotalLines
+			if entry.StartLine <= 0 && current.TotalLines > batchReadSmallFile {
+				end = batchReadSmallFile
+				current.Note = fmt.Sprintf("the file has %d lines; showing the first %d, ask for a range to see more",
+					current.TotalLines, batchReadSmallFile)
+			}
+		}
+		if end < start {
+			current.Error = fmt.Errorf("EndLine %d is before StartLine %d", end, start)
+			continue
+		}
+		view, err := file.ReadRange(start-1, end, remaining)
+		if err != nil {
+			current.Error = err
+			continue
+		}
+		remaining -= view.Chars
+		current.Lines = view.Lines
+		if view.Binary {
+			current.Note = "binary file, shown as a hex dump by byte offset"
+			continue
+		}
+		current.StartLine, current.EndLine = view.Start+1, view.End
+		if view.Truncated {
+			if current.Note != "" {
+				current.Note += "; "
+			}
+			current.Note += fmt.Sprintf("cut short by the token budget after line %d", view.End)
+		}
+	}
+	return
+}
@@ -0,0 +1,30 @@
+package actions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lighmon-even/filetool/base"
+)
+
+func TestBatchReadBinaryHasNoLineCount(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Newlines in binary data would otherwise be counted as lines.
+	if err := os.WriteFile(filepath.Join(dir, "a.bin"), []byte("\x00\x01\n\n\n\x02\x03\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	response := NewBatchRead().ExecuteOnFileManager(base.NewFileManager(dir), BatchReadRequest{
+		Entries: []BatchReadEntry{{FilePath: "a.txt"}, {FilePath: "a.bin"}},
+	})
+	text, binary := response.Results[0], response.Results[1]
+	if text.Error != nil || text.TotalLines != 2 || len(text.Lines) != 2 {
+		t.Errorf("text entry: %d of %d lines, %v", len(text.Lines), text.TotalLines, text.Error)
+	}
+	if binary.Error != nil || binary.TotalLines != 0 || len(binary.Lines) != 1 {
+		t.Errorf("binary entry: %d rows, TotalLines %d, %v; want one row and no line count", len(binary.Lines), binary.TotalLines, binary.Error)
+	}
+}
@@ -53,7 +53,7 @@ func NewChangeWorkingDirectory() *ChangeWorkingDirectory {
 //"""
 
 func (cwd *ChangeWorkingDirectory) ExecuteOnFileManager(
-	fileManager FileManager, requestData ChwdirRequest) *ChwdirResponse {
+	fileManager *FileManager, requestData ChwdirRequest) *ChwdirResponse {
 	err := fileManager.Chdir(requestData.path)
 	ncr := NewChwdirResponse("")
 	ncr.Error = err
@@ -70,7 +70,7 @@ func NewCreateFile() *CreateFile {
 	}
 }
 func (cf *CreateFile) ExecuteOnFileManager(
-	fileManager FileManager, requestData CreateFileRequest,
+	fileManager *FileManager, requestData CreateFileRequest,
 ) (cfr *CreateFileResponse) {
 	cfr = NewCreateFileResponse("", false)
 	newfile, err := fileManager.Create(requestData.FilePath)
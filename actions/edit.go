@@ -20,6 +20,19 @@ type EditFileRequest struct {
 	//"The line number at which the file edit will start (REQUIRED). Inclusive - the start line will be included in the edit.",
 	EndLine int
 	//"The line number at which the file edit will end (REQUIRED). Inclusive - the end line will be included in the edit.",
+	Mode string
+	//"How the text is applied: 'replace' (default) replaces StartLine-EndLine, "
+	//"'insert_before' and 'insert_after' insert the text before or after StartLine "
+	//"('insert_after' with 0 inserts at the top), 'append' adds it at the end of "
+	//"the file and 'delete' removes StartLine-EndLine.",
+	ExpectedText string
+	//"Optional. The current text of StartLine-EndLine as you last saw it. If "
+	//"the file no longer has it there, the edit is refused and the current "
+	//"lines are returned.",
+	Fingerprint string
+	//"Optional. The fingerprint returned when you last viewed or edited the "
+	//"file. If the file changed since, the edit is refused and the current "
+	//"lines are returned.",
 }
 
 type EditFileResponse struct {
@@ -32,6 +45,25 @@ type EditFileResponse struct {
 	//"Error message if any",
 	UpdatedText string
 	//"The updated text. If the file was not edited, this will be empty.",
+	Diagnostics []base.Diagnostic
+	//"Lint errors introduced by the edit, with line and column. When present "
+	//"the edit was not applied.",
+	Formatted bool
+	//"Whether the formatter changed the text after the edit.",
+	FinalText string
+	//"The edited region as written to the file, after formatting.",
+	Diff string
+	//"Unified diff of the change.",
+	Context string
+	//"The edited region with a few lines of context, prefixed with line numbers.",
+	Warnings []string
+	//"Likely mistakes in the edit, such as duplicated lines or unbalanced brackets.",
+	TotalLines int
+	//"Number of lines in the file after the edit.",
+	Encoding string
+	//"Character encoding the file was read and written in.",
+	Fingerprint string
+	//"Fingerprint of the file after the edit, to pass to the next edit.",
 }
 
 func NewEditFileResponse() *EditFileResponse {
@@ -40,6 +72,15 @@ func NewEditFileResponse() *EditFileResponse {
 		"",
 		nil,
 		"",
+		nil,
+		false,
+		"",
+		"",
+		"",
+		nil,
+		0,
+		"",
+		"",
 	}
 }
 
@@ -50,7 +91,7 @@ type EditFile struct {
 	//
 	//Please note that THE EDIT COMMAND REQUIRES PROPER INDENTATION.
 	//
-	//Python files will be checked for syntax errors after the edit.
+	//Go, JSON and YAML files will be checked for errors after the edit.
 	//If you'd like to add the line '        print(x)' you must fully write
 	//that out, with all those spaces before the code!
 	//
@@ -59,6 +100,9 @@ type EditFile struct {
 	//and modify the edit command you issue accordingly. Issuing the same command
 	//a second time will just lead to the same error message again.
 	//
+	//To make sure the file did not change since you viewed it, pass the
+	//Fingerprint you were given or the ExpectedText of the lines you replace.
+	//
 	//If a lint error occurs, the edit will not be applied. Review the error message,
 	//adjust your edit accordingly, and try again.
 	//Raises:
@@ -80,35 +124,56 @@ func NewEditFile() *EditFile {
 	}
 }
 func (ef *EditFile) ExecuteOnFileManager(
-	fileManager FileManager,
+	fileManager *FileManager,
 	requestData EditFileRequest,
 ) (efr *EditFileResponse) {
 	efr = NewEditFileResponse()
 	var file *base.File
 	var err error
 	if requestData.FilePath == "" {
-		file = fileManager.Recent
+		file = fileManager.RecentFile()
 	} else {
 		file, err = fileManager.Open(requestData.FilePath)
 	}
+	if err != nil {
+		efr.Error = err
+		return
+	}
 	if file == nil {
-		err = fmt.Errorf("file not found: %v", requestData.FilePath)
+		efr.Error = fmt.Errorf("file not found: %v", requestData.FilePath)
+		return
 	}
-	response := file.WriteAndRunLint(
-		requestData.Text,
-		requestData.StartLine,
-		requestData.EndLine,
-	)
+	response := file.WriteAndRunLint(base.LineEdit{
+		Mode:         base.EditMode(requestData.Mode),
+		Start:        requestData.StartLine,
+		End:          requestData.EndLine,
+		Text:         requestData.Text,
+		ExpectedText: requestData.ExpectedText,
+		Fingerprint:  requestData.Fingerprint,
+	})
+	efr.fill(response)
+	efr.Fingerprint = file.Fingerprint().Hash
+	return
+}
+
+// fill copies the result of a file edit into the response.
+func (efr *EditFileResponse) fill(response base.TextReplacement) {
+	efr.TotalLines = response.TotalLines
+	efr.Encoding = response.Encoding
 	if response.Error != nil && len(response.Error.Error()) > 0 {
 		efr.Error = errors.New("No Update, found error: " + response.Error.Error())
+		efr.Diagnostics = response.Diagnostics
+		var stale *base.StaleContentError
+		if errors.As(response.Error, &stale) {
+			efr.Context = stale.View
+		}
 		return
 	}
-	if err == nil {
-		efr.OldText = response.ReplacedText
-		efr.UpdatedText = response.ReplacedWith
-		return
-	} else {
-		efr.Error = err
-		return
-	}
+	efr.OldText = response.ReplacedText
+	efr.UpdatedText = response.ReplacedWith
+	efr.Formatted = response.Formatted
+	efr.FinalText = response.FinalText
+	efr.Diff = response.Diff
+	efr.Context = response.Context
+	efr.Warnings = response.Warnings
 }
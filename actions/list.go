@@ -1 +1,76 @@
 package actions
+
+import (
+	"fmt"
+
+	"github.com/lighmon-even/filetool/base"
+)
+
+type ListFilesRequest struct {
+	*BaseFileRequest
+	//"""Request to list the files in a directory or archive."""
+	Path string
+	//"The directory or archive to list, absolute or relative to the current "
+	//"working directory. Archives (.zip, .tar, .tar.gz, .tgz, .tar.bz2) are "
+	//"listed like directories. If not provided, the current working "
+	//"directory is listed.",
+	Depth int
+	//"How many levels of subdirectories and archives to descend into. 0 "
+	//"lists only the given directory; -1 lists everything below it.",
+	Archives bool
+	//"Whether to list the members of archives found below Path. Off by "
+	//"default, since every archive has to be decompressed to be listed.",
+}
+
+type ListFilesResponse struct {
+	*BaseFileResponse
+	//"""Response to a list files request."""
+	Files []base.FileEntry
+	//"The files, directories and archives found. Archive members are listed "
+	//"as 'archive.zip!/member' and can be opened with that path, but not "
+	//"written.",
+	Message string
+	//"Summary of what was listed.",
+}
+
+func NewListFilesResponse() *ListFilesResponse {
+	return &ListFilesResponse{
+		NewBaseFileResponse(""),
+		nil,
+		"",
+	}
+}
+
+type ListFiles struct {
+	*BaseFileAction
+	//"""
+	//Use this tool to see what is in a directory, including the members of
+	//zip and tar archives, without running shell commands. Compressed .gz
+	//and .bz2 files and archive members can then be read with the other
+	//tools as if they were ordinary files.
+	//"""
+	displayName    string             // = "List files"
+	requestSchema  *ListFilesRequest  // = ListFilesRequest
+	responseSchema *ListFilesResponse //= ListFilesResponse
+}
+
+func NewListFiles() *ListFiles {
+	return &ListFiles{
+		displayName: "List files",
+	}
+}
+
+func (lf *ListFiles) ExecuteOnFileManager(
+	fileManager *FileManager,
+	requestData ListFilesRequest,
+) (lfr *ListFilesResponse) {
+	lfr = NewListFilesResponse()
+	files, err := fileManager.ListFiles(requestData.Path, requestData.Depth, base.WithArchives(requestData.Archives))
+	if err != nil {
+		lfr.Error = err
+		return
+	}
+	lfr.Files = files
+	lfr.Message = fmt.Sprintf("Found %d entries.", len(files))
+	return
+}
@@ -0,0 +1,104 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/lighmon-even/filetool/base"
+)
+
+type EditHunk struct {
+	Text string
+	//"The text for this hunk."
+	StartLine int
+	//"First line of the hunk, numbered as in the file BEFORE any hunk is applied."
+	EndLine int
+	//"Last line of the hunk (inclusive), numbered as in the file BEFORE any hunk is applied."
+	Mode string
+	//"'replace' (default), 'insert_before', 'insert_after', 'append' or 'delete', "
+	//"with the same meaning as for EditFile."
+	ExpectedText string
+	//"Optional. The current text of StartLine-EndLine, checked like in EditFile."
+}
+
+type MultiEditFileRequest struct {
+	*BaseFileRequest
+	//"""Request to apply several edits to one file at once."""
+	FilePath string
+	//"The path to the file that will be edited. If not provided, "
+	//"THE CURRENTLY OPEN FILE will be edited."
+	Edits []EditHunk
+	//"The hunks to apply. They must not overlap, and all line numbers refer "
+	//"to the file as it is before the edit, so you do not need to account for "
+	//"lines added or removed by earlier hunks."
+	Fingerprint string
+	//"Optional. The fingerprint returned when you last viewed or edited the "
+	//"file; the edit is refused if the file changed since."
+}
+
+type MultiEditFileResponse struct {
+	*EditFileResponse
+	//"""Response to a multi-hunk edit. Diff covers all hunks."""
+}
+
+func NewMultiEditFileResponse() *MultiEditFileResponse {
+	return &MultiEditFileResponse{
+		NewEditFileResponse(),
+	}
+}
+
+type MultiEditFile struct {
+	*BaseFileAction
+	//"""
+	//Use this tool to make several changes to one file in a single step.
+	//
+	//Each hunk is addressed with the line numbers you saw when you viewed the
+	//file; they are not shifted by the other hunks. Overlapping hunks are
+	//rejected. All hunks are written at once, and like EditFile the whole edit
+	//is not applied if it introduces lint errors.
+	//"""
+	displayName    string                 // = "Edit several parts of a file"
+	requestSchema  *MultiEditFileRequest  // = MultiEditFileRequest
+	responseSchema *MultiEditFileResponse //= MultiEditFileResponse
+}
+
+func NewMultiEditFile() *MultiEditFile {
+	return &MultiEditFile{
+		displayName: "Edit several parts of a file",
+	}
+}
+
+func (mef *MultiEditFile) ExecuteOnFileManager(
+	fileManager *FileManager,
+	requestData MultiEditFileRequest,
+) (mefr *MultiEditFileResponse) {
+	mefr = NewMultiEditFileResponse()
+	var file *base.File
+	var err error
+	if requestData.FilePath == "" {
+		file = fileManager.RecentFile()
+	} else {
+		file, err = fileManager.Open(requestData.FilePath)
+	}
+	if err != nil {
+		mefr.Error = err
+		return
+	}
+	if file == nil {
+		mefr.Error = fmt.Errorf("file not found: %v", requestData.FilePath)
+		return
+	}
+	edits := make([]base.LineEdit, 0, len(requestData.Edits))
+	for _, hunk := range requestData.Edits {
+		edits = append(edits, base.LineEdit{
+			Mode:         base.EditMode(hunk.Mode),
+			Start:        hunk.StartLine,
+			End:          hunk.EndLine,
+			Text:         hunk.Text,
+			ExpectedText: hunk.ExpectedText,
+			Fingerprint:  requestData.Fingerprint,
+		})
+	}
+	mefr.fill(file.WriteAndRunLint(edits...))
+	mefr.Fingerprint = file.Fingerprint().Hash
+	return
+}
@@ -1 +1,155 @@
 package actions
+
+import "fmt"
+
+type OpenFileRequest struct {
+	*BaseFileRequest
+	//"""Request to open a file."""
+	FilePath string
+	//"File path to open in the editor. This is a REQUIRED field.",
+	LineNumber int
+	//"If file-number is given, file will be open on that line number. "
+	//"Otherwise, it will be open from the start of the file. With Outline, "
+	//"the outline starts at this line.",
+	Offset int
+	//"For binary files, the byte offset to show the file from.",
+	MaxTokens int
+	//"Optional. Stop the view after roughly this many tokens; lines that do "
+	//"not fit are left for the next view. Applies to later views of the file too.",
+	Outline bool
+	//"Go files only: show the whole file with function bodies collapsed to "
+	//"'{ ... N lines }'. Line numbers are those of the file. If the token "
+	//"budget cuts it short, open it again at EndLine+1 for the rest.",
+	Expand []string
+	//"With Outline, functions to show in full, by name ('Func', 'Type.Method') "
+	//"or by the number of any of their lines.",
+}
+
+type OpenFileResponse struct {
+	*BaseFileResponse
+	//"""Response to open a file."""
+	Message string
+	//"Message to display to the user",
+	Lines map[int]string
+	//"File content with their line numbers. For binary files, a hex and "
+	//"ASCII dump of 16 bytes per row, keyed by the byte offset of the row.",
+	TotalLines int
+	//"Number of lines in the file.",
+	StartLine int
+	//"First line shown.",
+	EndLine int
+	//"Last line shown. Open the file again at EndLine+1 to continue.",
+	Truncated bool
+	//"Whether the token budget cut the view short.",
+	Encoding string
+	//"Character encoding the file was detected in, such as 'utf-8', "
+	//"'utf-16le' or 'iso-8859-1'. Edits are written back in the same encoding.",
+	Fingerprint string
+	//"Fingerprint of the content shown. Pass it to EditFile to make sure "
+	//"the file has not changed in the meantime.",
+	Binary bool
+	//"Whether the file is binary. Binary files cannot be edited.",
+	ContentType string
+	//"MIME type detected from the file's content.",
+}
+
+func NewOpenFileResponse() *OpenFileResponse {
+	return &OpenFileResponse{
+		NewBaseFileResponse(""),
+		"",
+		nil,
+		0,
+		0,
+		0,
+		false,
+		"",
+		"",
+		false,
+		"",
+	}
+}
+
+type OpenFile struct {
+	*BaseFileAction
+	//"""
+	//Opens a file in the editor based on the provided file path,
+	//If line_number is provided, the window will be move after that line. (i.e. 100 lines after the line number will be displayed)
+	//
+	//Can result in:
+	//- FileNotFoundError: If file does not exist
+	//- IsADirectoryError: If path is a directory
+	//- OSError: If the file could not be opened
+	//"""
+	displayName    string            // = "Open File on workspace"
+	requestSchema  *OpenFileRequest  // = OpenFileRequest
+	responseSchema *OpenFileResponse //= OpenFileResponse
+}
+
+func NewOpenFile() *OpenFile {
+	return &OpenFile{
+		displayName: "Open File on workspace",
+	}
+}
+
+func (of *OpenFile) ExecuteOnFileManager(
+	fileManager *FileManager,
+	requestData OpenFileRequest,
+) (ofr *OpenFileResponse) {
+	ofr = NewOpenFileResponse()
+	file, err := fileManager.Open(requestData.FilePath)
+	if err != nil {
+		ofr.Error = err
+		return
+	}
+	if requestData.MaxTokens > 0 {
+		file.SetTokenBudget(requestData.MaxTokens)
+	}
+	if requestData.Outline {
+		outline, err := file.OutlineFrom(max(requestData.LineNumber, 1), requestData.Expand...)
+		if err != nil {
+			ofr.Error = err
+			return
+		}
+		ofr.Lines, ofr.Truncated = outline.Lines, outline.Truncated
+		ofr.TotalLines = file.TotalLines()
+		ofr.Encoding = file.Encoding()
+		ofr.StartLine, ofr.EndLine = outline.Start+1, outline.End
+		ofr.Message = fmt.Sprintf("Outline of the file opened, %d of %d lines shown.", len(ofr.Lines), ofr.TotalLines)
+		if outline.Truncated {
+			ofr.Message += fmt.Sprintf(" The token budget ran out after line %d; open the outline at line %d for the rest.", outline.End, outline.End+1)
+		}
+		return
+	}
+	ofr.Binary, ofr.ContentType, err = file.IsBinary()
+	if err != nil {
+		ofr.Error = err
+		return
+	}
+	if ofr.Binary {
+		file.GotoOffset(requestData.Offset)
+	} else {
+		// A file opened again without a line number starts from the top,
+		// not where its window was left.
+		file.Goto(max(requestData.LineNumber-1, 0))
+	}
+	view, err := file.View()
+	if err != nil {
+		ofr.Error = err
+		return
+	}
+	ofr.Lines, ofr.Truncated = view.Lines, view.Truncated
+	if ofr.Binary {
+		ofr.Message = fmt.Sprintf("Binary file (%s) opened as a hex dump.", ofr.ContentType)
+		return
+	}
+	ofr.StartLine, ofr.EndLine = view.Start+1, view.End
+	ofr.TotalLines = file.TotalLines()
+	ofr.Encoding = file.Encoding()
+	ofr.Fingerprint = file.Fingerprint().Hash
+	ofr.Message = fmt.Sprintf("File opened successfully. Showing lines %d-%d of %d.",
+		ofr.StartLine, ofr.EndLine, ofr.TotalLines)
+	if view.Truncated {
+		ofr.Message += " The view was cut short by the token budget."
+	}
+	return
+}
@@ -0,0 +1,31 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lighmon-even/filetool/base"
+)
+
+func TestOpenFileWithoutLineNumberStartsAtTop(t *testing.T) {
+	dir := t.TempDir()
+	var text strings.Builder
+	for i := 1; i <= 300; i++ {
+		fmt.Fprintf(&text, "line %d\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(text.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fm := base.NewFileManager(dir)
+	open := NewOpenFile()
+	if response := open.ExecuteOnFileManager(fm, OpenFileRequest{FilePath: "a.txt", LineNumber: 150}); response.StartLine != 150 {
+		t.Fatalf("opened at line 150 but shows from line %d (%v)", response.StartLine, response.Error)
+	}
+	response := open.ExecuteOnFileManager(fm, OpenFileRequest{FilePath: "a.txt"})
+	if response.Error != nil || response.StartLine != 1 || response.Lines[1] != "line 1" {
+		t.Errorf("opened again without a line number, shows from line %d (%v)", response.StartLine, response.Error)
+	}
+}
@@ -0,0 +1,176 @@
+package actions
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/lighmon-even/filetool/base"
+)
+
+type SearchFileRequest struct {
+	*BaseFileRequest
+	//"""Request to search inside a file."""
+	FilePath string
+	//"The path to the file to search. If not provided, THE CURRENTLY OPEN "
+	//"FILE is searched.",
+	Pattern string
+	//"Regular expression to search for (Go RE2 syntax). Escape special "
+	//"characters such as '(' or '.' to match them literally.",
+	ContextLines int
+	//"Number of lines to show before and after each match.",
+	Mode string
+	//"'all' (default) lists every match in the file. 'next' and 'previous' "
+	//"move the open window to the next or previous match after the last one "
+	//"visited, wrapping around the end of the file, and show the window.",
+	MaxTokens int
+	//"Roughly how many tokens the listed matches may use. Matches are listed "
+	//"in order until it runs out.",
+}
+
+type SearchFileMatch struct {
+	Line int
+	//"1-based line number of the match.",
+	Column int
+	//"1-based column the match starts at.",
+	Text string
+	//"The matched text, cut like long lines are in views.",
+	Context string
+	//"The match's line with ContextLines lines around it, prefixed with line numbers.",
+}
+
+type SearchFileResponse struct {
+	*BaseFileResponse
+	//"""Response to a search inside a file."""
+	Matches []SearchFileMatch
+	//"The matches found. In 'next' and 'previous' mode, only the match moved to.",
+	Lines map[int]string
+	//"In 'next' and 'previous' mode, the window around the match with line numbers.",
+	Message string
+	//"Summary of the search.",
+	Truncated bool
+	//"Whether the token budget left out some of the matches.",
+}
+
+func NewSearchFileResponse() *SearchFileResponse {
+	return &SearchFileResponse{
+		NewBaseFileResponse(""),
+		nil,
+		nil,
+		"",
+		false,
+	}
+}
+
+// searchFileDefaultTokens is the budget of the listed matches when the
+// request sets none.
+const searchFileDefaultTokens = 8000
+
+type SearchFile struct {
+	*BaseFileAction
+	//"""
+	//Use this tool to search for a regular expression inside one file.
+	//
+	//In 'all' mode every match is listed with its line and column. To walk
+	//through matches one at a time, use 'next' or 'previous': the file's
+	//window is centered on each match in turn, so you can read around it and
+	//edit it with the line numbers shown.
+	//"""
+	displayName    string              // = "Search in a file"
+	requestSchema  *SearchFileRequest  // = SearchFileRequest
+	responseSchema *SearchFileResponse //= SearchFileResponse
+}
+
+func NewSearchFile() *SearchFile {
+	return &SearchFile{
+		displayName: "Search in a file",
+	}
+}
+
+func (sf *SearchFile) ExecuteOnFileManager(
+	fileManager *FileManager,
+	requestData SearchFileRequest,
+) (sfr *SearchFileResponse) {
+	sfr = NewSearchFileResponse()
+	var file *base.File
+	var err error
+	if requestData.FilePath == "" {
+		file = fileManager.RecentFile()
+	} else {
+		file, err = fileManager.Open(requestData.FilePath)
+	}
+	if err != nil {
+		sfr.Error = err
+		return
+	}
+	if file == nil {
+		sfr.Error = fmt.Errorf("file not found: %v", requestData.FilePath)
+		return
+	}
+
+	if requestData.Pattern == "" {
+		sfr.Error = fmt.Errorf("no pattern given to search for")
+		return
+	}
+	var matches []base.Match
+	switch requestData.Mode {
+	case "", "all":
+		matches, err = file.Find(requestData.Pattern, base.ScopeFile)
+		if err != nil {
+			sfr.Error = err
+			return
+		}
+		sfr.Message = fmt.Sprintf("Found %d matches for %q.", len(matches), requestData.Pattern)
+	case "next", "previous":
+		match, wrapped, ok, err := file.FindNext(requestData.Pattern, base.SearchDirection(requestData.Mode))
+		if err != nil {
+			sfr.Error = err
+			return
+		}
+		if !ok {
+			sfr.Message = fmt.Sprintf("No matches for %q.", requestData.Pattern)
+			return
+		}
+		matches = []base.Match{match}
+		sfr.Message = fmt.Sprintf("Moved to the match on line %d.", match.Lineno)
+		if wrapped {
+			sfr.Message += " The search wrapped around the end of the file."
+		}
+		if sfr.Lines, err = file.Read(); err != nil {
+			sfr.Error = err
+			return
+		}
+	default:
+		sfr.Error = fmt.Errorf("unknown mode %q, use 'all', 'next' or 'previous'", requestData.Mode)
+		return
+	}
+
+	tokens := requestData.MaxTokens
+	if tokens <= 0 {
+		tokens = searchFileDefaultTokens
+	}
+	remaining := tokens * base.CharsPerToken
+	for _, match := range matches {
+		excerpt, err := file.Excerpt(match.Lineno, max(requestData.ContextLines, 0))
+		if err != nil {
+			sfr.Error = err
+			return
+		}
+		found := SearchFileMatch{
+			Line:    match.Lineno,
+			Column:  utf8.RuneCountInString(match.Content[:match.Start]) + 1,
+			Text:    file.TruncateLine(match.Match),
+			Context: excerpt,
+		}
+		// The first match is always listed, so the search shows something.
+		chars := utf8.RuneCountInString(found.Text) + utf8.RuneCountInString(found.Context)
+		if chars > remaining && len(sfr.Matches) > 0 {
+			sfr.Truncated = true
+			sfr.Message += fmt.Sprintf(" Showing the first %d; the token budget ran out, search a narrower pattern or raise MaxTokens to see the rest.",
+				len(sfr.Matches))
+			break
+		}
+		remaining -= chars
+		sfr.Matches = append(sfr.Matches, found)
+	}
+	return
+}
@@ -0,0 +1,30 @@
+package actions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lighmon-even/filetool/base"
+)
+
+func TestSearchFileAllStopsAtBudget(t *testing.T) {
+	dir := t.TempDir()
+	text := strings.Repeat("a needle in a line of forty characters.\n", 1000)
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fm := base.NewFileManager(dir)
+	search := NewSearchFile()
+	response := search.ExecuteOnFileManager(fm, SearchFileRequest{FilePath: "a.txt", Pattern: "needle", MaxTokens: 100})
+	if response.Error != nil {
+		t.Fatal(response.Error)
+	}
+	if !response.Truncated || len(response.Matches) == 0 || len(response.Matches) >= 1000 {
+		t.Errorf("listed %d of 1000 matches, truncated %v", len(response.Matches), response.Truncated)
+	}
+	if !strings.Contains(response.Message, "Found 1000 matches") || !strings.Contains(response.Message, "token budget") {
+		t.Errorf("Message = %q, want the total and the cut", response.Message)
+	}
+}
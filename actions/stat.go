@@ -0,0 +1,74 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/lighmon-even/filetool/base"
+)
+
+type StatFilesRequest struct {
+	*BaseFileRequest
+	//"""Request to describe one or more files without reading them."""
+	FilePaths []string
+	//"The files or directories to describe, absolute or relative to the "
+	//"current working directory.",
+}
+
+type StatFilesResponse struct {
+	*BaseFileResponse
+	//"""Response to a stat request."""
+	Files []base.FileStat
+	//"One entry per path, in the order of the request: size, mode, owner "
+	//"UID and GID, modification time, symlink target, MIME type, whether "
+	//"the file is binary, its encoding, line count and line ending style "
+	//"(lf, crlf, cr, mixed or none). Error is set for paths that could not "
+	//"be examined.",
+	Message string
+	//"Summary of the files described.",
+}
+
+func NewStatFilesResponse() *StatFilesResponse {
+	return &StatFilesResponse{
+		NewBaseFileResponse(""),
+		nil,
+		"",
+	}
+}
+
+type StatFiles struct {
+	*BaseFileAction
+	//"""
+	//Use this tool to decide whether and how to open files: it reports what
+	//"ls -l", "file" and "wc -l" would, for several files at once, without
+	//returning their content.
+	//"""
+	displayName    string             // = "Describe files"
+	requestSchema  *StatFilesRequest  // = StatFilesRequest
+	responseSchema *StatFilesResponse //= StatFilesResponse
+}
+
+func NewStatFiles() *StatFiles {
+	return &StatFiles{
+		displayName: "Describe files",
+	}
+}
+
+func (sf *StatFiles) ExecuteOnFileManager(
+	fileManager *FileManager,
+	requestData StatFilesRequest,
+) (sfr *StatFilesResponse) {
+	sfr = NewStatFilesResponse()
+	if len(requestData.FilePaths) == 0 {
+		sfr.Error = fmt.Errorf("no file paths given")
+		return
+	}
+	sfr.Files = fileManager.Stat(requestData.FilePaths...)
+	failed := 0
+	for _, stat := range sfr.Files {
+		if stat.Error != nil {
+			failed++
+		}
+	}
+	sfr.Message = fmt.Sprintf("Described %d of %d paths.", len(sfr.Files)-failed, len(sfr.Files))
+	return
+}
@@ -0,0 +1,120 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lighmon-even/filetool/base"
+)
+
+type StrReplaceRequest struct {
+	*BaseFileRequest
+	//"""Request to replace a piece of text in a file."""
+	FilePath string
+	//"The path to the file that will be edited. If not provided, "
+	//"THE CURRENTLY OPEN FILE will be edited."
+	OldText string
+	//"The exact text to replace, including indentation and line breaks.",
+	NewText string
+	//"The text that replaces OldText.",
+	Occurrence int
+	//"Which occurrence to replace (1-based) when OldText occurs more than once.",
+	ReplaceAll bool
+	//"Replace every occurrence of OldText.",
+	Regex bool
+	//"Treat OldText as a regular expression. NewText may then use $1 or ${name} "
+	//"to refer to capture groups.",
+	Fuzzy bool
+	//"When OldText does not occur exactly, match whole lines that differ only "
+	//"in indentation or surrounding whitespace, or failing that the most "
+	//"similar lines. NewText is reindented to match the replaced lines.",
+}
+
+type StrReplaceResponse struct {
+	*EditFileResponse
+	//"""Response to a search and replace edit."""
+	Candidates []base.Match
+	//"When OldText occurs more than once, every occurrence with its line number.",
+	Replacements int
+	//"Number of occurrences replaced.",
+	Strategy string
+	//"How OldText was found: 'exact', 'regex', 'indentation', 'whitespace' or 'similar'.",
+	Similarity float64
+	//"How close the replaced text was to OldText, from 0 to 1. OldText in the "
+	//"response holds the text exactly as it was in the file.",
+}
+
+func NewStrReplaceResponse() *StrReplaceResponse {
+	return &StrReplaceResponse{
+		NewEditFileResponse(),
+		nil,
+		0,
+		"",
+		0,
+	}
+}
+
+type StrReplace struct {
+	*BaseFileAction
+	//"""
+	//Use this tool to replace a piece of text in a file without counting lines.
+	//
+	//OldText must match the file exactly, whitespace included, and must occur
+	//only once. If it occurs several times the edit is not applied and every
+	//candidate is returned with its line number; add surrounding lines to make
+	//OldText unique, or pass Occurrence or ReplaceAll. With Fuzzy, OldText that
+	//is off in indentation or whitespace still matches; check Strategy and
+	//OldText in the response to see what was actually replaced.
+	//
+	//Like EditFile, the edit is not applied if it introduces lint errors.
+	//"""
+	displayName    string              // = "Replace text in a file"
+	requestSchema  *StrReplaceRequest  // = StrReplaceRequest
+	responseSchema *StrReplaceResponse //= StrReplaceResponse
+}
+
+func NewStrReplace() *StrReplace {
+	return &StrReplace{
+		displayName: "Replace text in a file",
+	}
+}
+
+func (sr *StrReplace) ExecuteOnFileManager(
+	fileManager *FileManager,
+	requestData StrReplaceRequest,
+) (srr *StrReplaceResponse) {
+	srr = NewStrReplaceResponse()
+	var file *base.File
+	var err error
+	if requestData.FilePath == "" {
+		file = fileManager.RecentFile()
+	} else {
+		file, err = fileManager.Open(requestData.FilePath)
+	}
+	if err != nil {
+		srr.Error = err
+		return
+	}
+	if file == nil {
+		srr.Error = fmt.Errorf("file not found: %v", requestData.FilePath)
+		return
+	}
+	response := file.ReplaceAndRunLint(
+		requestData.OldText,
+		requestData.NewText,
+		base.WithOccurrence(requestData.Occurrence),
+		base.WithReplaceAll(requestData.ReplaceAll),
+		base.WithRegex(requestData.Regex),
+		base.WithFuzzy(requestData.Fuzzy),
+	)
+	srr.fill(response)
+	srr.Fingerprint = file.Fingerprint().Hash
+	srr.Replacements = response.Replacements
+	srr.Strategy = string(response.Strategy)
+	srr.Similarity = response.Similarity
+	var ambiguous *base.AmbiguousMatchError
+	if errors.As(response.Error, &ambiguous) {
+		srr.Candidates = ambiguous.Candidates
+	}
+	return
+}
@@ -0,0 +1,111 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/lighmon-even/filetool/base"
+)
+
+type TailFileRequest struct {
+	*BaseFileRequest
+	//"""Request to read the end of a file or follow it as it grows."""
+	FilePath string
+	//"The path to the file, usually a log. If not provided, THE CURRENTLY "
+	//"OPEN FILE is used.",
+	Lines int
+	//"How many lines to show from the end of the file. Defaults to 50.",
+	Follow bool
+	//"Return only the lines added since the previous TailFile call on this "
+	//"file, instead of the last Lines lines; the first call returns the last "
+	//"Lines lines. Call it again to keep polling.",
+}
+
+type TailFileResponse struct {
+	*BaseFileResponse
+	//"""Response to a tail or follow request."""
+	Lines []string
+	//"The lines read, oldest first.",
+	Offset int64
+	//"Byte offset in the file that the next follow continues from.",
+	Rotated bool
+	//"Whether the file was truncated or replaced since the previous call, "
+	//"in which case it was read again from the beginning.",
+	More bool
+	//"When following, whether more new lines are waiting; call again to get "
+	//"them. Otherwise, whether the size limit left out earlier lines or cut "
+	//"the last one.",
+	Message string
+	//"Summary of what was read.",
+}
+
+func NewTailFileResponse() *TailFileResponse {
+	return &TailFileResponse{
+		NewBaseFileResponse(""),
+		nil,
+		0,
+		false,
+		false,
+		"",
+	}
+}
+
+type TailFile struct {
+	*BaseFileAction
+	//"""
+	//Use this tool to watch a log file. Without Follow it shows the last
+	//lines of the file without reading all of it. With Follow it returns
+	//only what was appended since the previous call, so you can poll a
+	//growing log cheaply; log rotation and truncation are detected.
+	//"""
+	displayName    string            // = "Tail a file"
+	requestSchema  *TailFileRequest  // = TailFileRequest
+	responseSchema *TailFileResponse //= TailFileResponse
+}
+
+func NewTailFile() *TailFile {
+	return &TailFile{
+		displayName: "Tail a file",
+	}
+}
+
+func (tf *TailFile) ExecuteOnFileManager(
+	fileManager *FileManager,
+	requestData TailFileRequest,
+) (tfr *TailFileResponse) {
+	tfr = NewTailFileResponse()
+	var file *base.File
+	var err error
+	if requestData.FilePath == "" {
+		file = fileManager.RecentFile()
+	} else {
+		file, err = fileManager.Open(requestData.FilePath)
+	}
+	if err != nil {
+		tfr.Error = err
+		return
+	}
+	if file == nil {
+		tfr.Error = fmt.Errorf("file not found: %v", requestData.FilePath)
+		return
+	}
+	lines := requestData.Lines
+	if lines <= 0 {
+		lines = 50
+	}
+	var result base.TailResult
+	if requestData.Follow {
+		result, err = file.Follow(lines)
+	} else {
+		result, err = file.Tail(lines)
+	}
+	if err != nil {
+		tfr.Error = err
+		return
+	}
+	tfr.Lines, tfr.Offset, tfr.Rotated, tfr.More = result.Lines, result.Offset, result.Rotated, result.More
+	tfr.Message = fmt.Sprintf("Read %d lines, up to byte %d.", len(result.Lines), result.Offset)
+	if result.Rotated {
+		tfr.Message += " The file was rotated or truncated and was read from the beginning."
+	}
+	return
+}
@@ -7,7 +7,6 @@ import (
 	"path/filepath"
 	"reflect"
 	"strings"
-	"unicode/utf8"
 )
 
 type Request interface {
@@ -155,8 +154,8 @@ func (b *BaseAction) ExecuteAction(
 				Error = err
 				break
 			}
-			if utf8.Valid(fileContent) { //  # Try decoding	as UTF - 8 to check	if it's normal text
-				modifiedRequestData[param] = string(fileContent)
+			if text, _, ok := DecodeText(fileContent); ok { //  # Decode text in any detected encoding to UTF-8
+				modifiedRequestData[param] = text
 			} else { //# If decoding fails, treat as binary and encode in base64
 				modifiedRequestData[param] = base64.StdEncoding.EncodeToString(fileContent)
 			}
@@ -0,0 +1,538 @@
+package base
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrVirtualFile is returned for writes to compressed files and archive
+// members, which are read-only views of the file that contains them.
+var ErrVirtualFile = errors.New("compressed files and archive members are read-only")
+
+// ArchiveSeparator separates an archive from the member path inside it, as in
+// "fixtures.zip!/data/x.json".
+const ArchiveSeparator = "!"
+
+// maxVirtualSize caps how many bytes a compressed file or archive may expand
+// to, so a decompression bomb fails instead of exhausting memory.
+const maxVirtualSize = 256 << 20
+
+// maxArchiveCache caps the decoded bytes ArchiveFS keeps across files; the
+// least recently used are dropped first.
+const maxArchiveCache = 64 << 20
+
+type compression string
+
+const (
+	compressionNone  compression = ""
+	compressionGzip  compression = "gzip"
+	compressionBzip2 compression = "bzip2"
+)
+
+// compressionOf returns the compression a file name's extension implies.
+func compressionOf(name string) compression {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".gz", ".tgz":
+		return compressionGzip
+	case ".bz2", ".tbz2", ".tbz":
+		return compressionBzip2
+	}
+	return compressionNone
+}
+
+// IsArchive reports whether name has the extension of an archive whose
+// members can be addressed with ArchiveSeparator.
+func IsArchive(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range []string{".zip", ".jar", ".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tbz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitArchivePath splits name into the archive it points into and the
+// slash-separated member path. ok is false when name is an ordinary path.
+// "a.zip!" is the root of the archive and has an empty member.
+func splitArchivePath(name string) (archive string, member string, ok bool) {
+	for i := 0; i < len(name); i++ {
+		j := strings.Index(name[i:], ArchiveSeparator)
+		if j < 0 {
+			break
+		}
+		i += j
+		rest := name[i+len(ArchiveSeparator):]
+		if !IsArchive(name[:i]) || (rest != "" && rest[0] != filepath.Separator && rest[0] != '/') {
+			continue
+		}
+		member = strings.Trim(path.Clean("/"+filepath.ToSlash(rest)), "/")
+		return name[:i], member, true
+	}
+	return "", "", false
+}
+
+// ArchiveFS wraps an FS so gzip and bzip2 files read as their decompressed
+// content and members of zip and tar archives can be addressed as
+// "archive.zip!/member". Both are read-only; writing them fails with
+// ErrVirtualFile. Everything else passes through to the wrapped FS.
+type ArchiveFS struct {
+	FS
+	mu     sync.Mutex
+	cache  map[string]*archiveContent
+	cached int
+	clock  uint64
+}
+
+// defaultFS is used by files created without a FileManager.
+var defaultFS FS = NewArchiveFS(OSFS{})
+
+func NewArchiveFS(fsys FS) *ArchiveFS {
+	return &ArchiveFS{FS: fsys, cache: make(map[string]*archiveContent)}
+}
+
+// archiveContent is the decoded content of a compressed file or archive,
+// valid while the file keeps the size and modification time it had.
+type archiveContent struct {
+	size    int64
+	modTime time.Time
+	// data is the decompressed content of the file.
+	data []byte
+	// members maps the member paths of an archive, directories included, to
+	// their entries. The root is stored under "".
+	members map[string]*archiveMember
+	// err is why a file with a compressed extension did not decompress.
+	// Such a file is read as it is on disk, and its members cannot be.
+	err error
+	// weight is the bytes held in data and members; used orders evictions.
+	weight int
+	used   uint64
+}
+
+type archiveMember struct {
+	name     string
+	data     []byte
+	mode     fs.FileMode
+	modTime  time.Time
+	children []string
+}
+
+func (m *archiveMember) Name() string       { return path.Base("/" + m.name) }
+func (m *archiveMember) Size() int64        { return int64(len(m.data)) }
+func (m *archiveMember) Mode() fs.FileMode  { return m.mode }
+func (m *archiveMember) ModTime() time.Time { return m.modTime }
+func (m *archiveMember) IsDir() bool        { return m.mode.IsDir() }
+func (m *archiveMember) Sys() any           { return nil }
+
+// virtualInfo describes a decompressed file under its own name.
+type virtualInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (v virtualInfo) Size() int64 { return v.size }
+
+// lazyInfo describes a compressed file whose decompressed size is only
+// worked out when Size is called, so a Stat that only needs the file's type
+// or modification time does not decompress it.
+type lazyInfo struct {
+	fs.FileInfo
+	size func() int64
+}
+
+func (l lazyInfo) Size() int64 { return l.size() }
+
+// virtualFile is an open compressed file or archive member.
+type virtualFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (v *virtualFile) Stat() (fs.FileInfo, error) { return v.info, nil }
+func (v *virtualFile) Close() error               { return nil }
+
+func virtualError(op string, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: ErrVirtualFile}
+}
+
+// load returns the decoded content of the compressed file or archive name,
+// reading it again when it changed since the last call.
+func (a *ArchiveFS) load(name string) (*archiveContent, error) {
+	info, err := a.FS.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+	a.mu.Lock()
+	cached := a.cache[name]
+	if cached != nil && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		a.clock++
+		cached.used = a.clock
+		a.mu.Unlock()
+		return cached, nil
+	}
+	a.mu.Unlock()
+
+	raw, err := a.FS.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	content := &archiveContent{size: int64(len(raw)), modTime: info.ModTime()}
+	data, err := decompress(raw, compressionOf(name))
+	switch {
+	case err != nil:
+		content.err = fmt.Errorf("could not decompress %s: %v", name, err)
+	case IsArchive(name):
+		content.data = data
+		if content.members, err = readArchive(data, name, info.ModTime()); err != nil {
+			content.err = fmt.Errorf("could not read archive %s: %v", name, err)
+		}
+	default:
+		content.data = data
+	}
+	if content.size == info.Size() {
+		a.store(name, content)
+	}
+	return content, nil
+}
+
+// store caches content under name, evicting the least recently used entries
+// to stay within maxArchiveCache. Content larger than that is not cached.
+func (a *ArchiveFS) store(name string, content *archiveContent) {
+	content.weight = len(content.data)
+	for _, m := range content.members {
+		content.weight += len(m.data)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if old, ok := a.cache[name]; ok {
+		a.cached -= old.weight
+		delete(a.cache, name)
+	}
+	if content.weight > maxArchiveCache {
+		return
+	}
+	for a.cached+content.weight > maxArchiveCache {
+		oldest := ""
+		for cachedName, cached := range a.cache {
+			if oldest == "" || cached.used < a.cache[oldest].used {
+				oldest = cachedName
+			}
+		}
+		a.cached -= a.cache[oldest].weight
+		delete(a.cache, oldest)
+	}
+	a.clock++
+	content.used = a.clock
+	a.cache[name] = content
+	a.cached += content.weight
+}
+
+func decompress(raw []byte, kind compression) ([]byte, error) {
+	var r io.Reader
+	switch kind {
+	case compressionGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	case compressionBzip2:
+		r = bzip2.NewReader(bytes.NewReader(raw))
+	default:
+		return raw, nil
+	}
+	return readLimited(r)
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxVirtualSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxVirtualSize {
+		return nil, fmt.Errorf("content exceeds %d bytes", maxVirtualSize)
+	}
+	return data, nil
+}
+
+// readArchive lists the members of the zip or tar archive in data. Parent
+// directories missing from the archive are added, and member paths are
+// cleaned so they cannot point outside the archive.
+func readArchive(data []byte, name string, modTime time.Time) (map[string]*archiveMember, error) {
+	members := map[string]*archiveMember{"": {mode: fs.ModeDir | 0555, modTime: modTime}}
+	total := 0
+	add := func(memberPath string, isDir bool, mode fs.FileMode, modTime time.Time, content []byte) error {
+		memberPath = strings.Trim(path.Clean("/"+memberPath), "/")
+		if memberPath == "" {
+			return nil
+		}
+		if total += len(content); total > maxVirtualSize {
+			return fmt.Errorf("content exceeds %d bytes", maxVirtualSize)
+		}
+		if isDir {
+			mode = fs.ModeDir | mode.Perm()
+		} else {
+			mode = mode.Perm()
+		}
+		if existing, ok := members[memberPath]; ok && isDir {
+			existing.mode, existing.modTime = mode, modTime
+			return nil
+		}
+		members[memberPath] = &archiveMember{name: memberPath, data: content, mode: mode, modTime: modTime}
+		for child := memberPath; child != ""; {
+			parent := path.Dir(child)
+			if parent == "." {
+				parent = ""
+			}
+			dir, ok := members[parent]
+			if !ok {
+				dir = &archiveMember{name: parent, mode: fs.ModeDir | 0555, modTime: modTime}
+				members[parent] = dir
+			}
+			if !slices.Contains(dir.children, child) {
+				dir.children = append(dir.children, child)
+			}
+			if ok {
+				break
+			}
+			child = parent
+		}
+		return nil
+	}
+
+	if strings.HasSuffix(strings.ToLower(name), ".zip") || strings.HasSuffix(strings.ToLower(name), ".jar") {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range zr.File {
+			var content []byte
+			if !entry.FileInfo().IsDir() {
+				rc, err := entry.Open()
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", entry.Name, err)
+				}
+				content, err = readLimited(rc)
+				_ = rc.Close()
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", entry.Name, err)
+				}
+			}
+			if err := add(entry.Name, entry.FileInfo().IsDir(), entry.Mode(), entry.Modified, content); err != nil {
+				return nil, err
+			}
+		}
+		return members, nil
+	}
+
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return members, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = add(header.Name, true, fs.FileMode(header.Mode), header.ModTime, nil)
+		case tar.TypeReg:
+			content, readErr := readLimited(tr)
+			if readErr != nil {
+				return nil, fmt.Errorf("%s: %v", header.Name, readErr)
+			}
+			err = add(header.Name, false, fs.FileMode(header.Mode), header.ModTime, content)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// member returns the entry for an archive member path.
+func (a *ArchiveFS) member(op string, name string) (*archiveMember, error) {
+	archive, memberPath, _ := splitArchivePath(name)
+	content, err := a.load(archive)
+	if err != nil {
+		return nil, err
+	}
+	if content.err != nil {
+		return nil, content.err
+	}
+	m, ok := content.members[memberPath]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return m, nil
+}
+
+func (a *ArchiveFS) Open(name string) (fs.File, error) {
+	if _, _, ok := splitArchivePath(name); ok {
+		m, err := a.member("open", name)
+		if err != nil {
+			return nil, err
+		}
+		return &virtualFile{Reader: bytes.NewReader(m.data), info: m}, nil
+	}
+	if compressionOf(name) == compressionNone {
+		return a.FS.Open(name)
+	}
+	info, data, err := a.decompressed(name)
+	if err != nil {
+		return nil, err
+	}
+	return &virtualFile{Reader: bytes.NewReader(data), info: info}, nil
+}
+
+// decompressed returns the stat and content of a compressed file. A file
+// that does not decompress, such as an empty one, is returned as it is.
+func (a *ArchiveFS) decompressed(name string) (fs.FileInfo, []byte, error) {
+	info, err := a.FS.Stat(name)
+	if err != nil || info.IsDir() {
+		return info, nil, err
+	}
+	content, err := a.load(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if content.err != nil {
+		raw, err := a.FS.ReadFile(name)
+		return info, raw, err
+	}
+	return virtualInfo{FileInfo: info, size: int64(len(content.data))}, content.data, nil
+}
+
+func (a *ArchiveFS) Stat(name string) (fs.FileInfo, error) {
+	if _, _, ok := splitArchivePath(name); ok {
+		return a.member("stat", name)
+	}
+	if compressionOf(name) == compressionNone {
+		return a.FS.Stat(name)
+	}
+	info, err := a.FS.Stat(name)
+	if err != nil || info.IsDir() {
+		return info, err
+	}
+	return lazyInfo{FileInfo: info, size: sync.OnceValue(func() int64 {
+		content, err := a.load(name)
+		if err != nil || content.err != nil {
+			return info.Size()
+		}
+		return int64(len(content.data))
+	})}, nil
+}
+
+func (a *ArchiveFS) ReadFile(name string) ([]byte, error) {
+	if _, _, ok := splitArchivePath(name); ok {
+		m, err := a.member("read", name)
+		if err != nil {
+			return nil, err
+		}
+		if m.IsDir() {
+			return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+		}
+		return slices.Clone(m.data), nil
+	}
+	if compressionOf(name) == compressionNone {
+		return a.FS.ReadFile(name)
+	}
+	_, data, err := a.decompressed(name)
+	return slices.Clone(data), err
+}
+
+// ReadDir lists a directory, or the members of an archive directory when name
+// is "archive.zip!" or a directory inside it.
+func (a *ArchiveFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if _, _, ok := splitArchivePath(name); !ok {
+		return a.FS.ReadDir(name)
+	}
+	m, err := a.member("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !m.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	archive, _, _ := splitArchivePath(name)
+	content, err := a.load(archive)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, 0, len(m.children))
+	for _, child := range m.children {
+		entries = append(entries, fs.FileInfoToDirEntry(content.members[child]))
+	}
+	slices.SortFunc(entries, func(x, y fs.DirEntry) int { return strings.Compare(x.Name(), y.Name()) })
+	return entries, nil
+}
+
+// WriteFile refuses archive members and existing compressed files, whose
+// decompressed content cannot be written back. New files, and files with a
+// compressed extension that do not decompress, are written as they are.
+func (a *ArchiveFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if _, _, ok := splitArchivePath(name); ok {
+		return virtualError("write", name)
+	}
+	if compressionOf(name) != compressionNone {
+		if content, err := a.load(name); err == nil && content.err == nil {
+			return virtualError("write", name)
+		}
+	}
+	return a.FS.WriteFile(name, data, perm)
+}
+
+func (a *ArchiveFS) MkdirAll(name string, perm fs.FileMode) error {
+	if _, _, ok := splitArchivePath(name); ok {
+		return virtualError("mkdir", name)
+	}
+	return a.FS.MkdirAll(name, perm)
+}
+
+func (a *ArchiveFS) Remove(name string) error {
+	if _, _, ok := splitArchivePath(name); ok {
+		return virtualError("remove", name)
+	}
+	return a.FS.Remove(name)
+}
+
+func (a *ArchiveFS) Rename(oldname string, newname string) error {
+	for _, name := range []string{oldname, newname} {
+		if _, _, ok := splitArchivePath(name); ok {
+			return virtualError("rename", name)
+		}
+	}
+	return a.FS.Rename(oldname, newname)
+}
+
+func (a *ArchiveFS) Chmod(name string, mode fs.FileMode) error {
+	if _, _, ok := splitArchivePath(name); ok {
+		return virtualError("chmod", name)
+	}
+	return a.FS.Chmod(name, mode)
+}
+
+// unwrapArchiveFS returns the FS an ArchiveFS wraps, or fsys itself.
+func unwrapArchiveFS(fsys FS) FS {
+	if a, ok := fsys.(*ArchiveFS); ok {
+		return a.FS
+	}
+	return fsys
+}
@@ -0,0 +1,127 @@
+package base
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func gzipped(t *testing.T, text string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveReads(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt.gz"), gzipped(t, "hello\nworld\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("data/x.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("{\"a\": 1}\n"))
+	zw.Close()
+	if err := os.WriteFile(filepath.Join(dir, "fixtures.zip"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fm := NewFileManager(dir)
+	for path, want := range map[string]string{"a.txt.gz": "world", "fixtures.zip!/data/x.json": `{"a": 1}`} {
+		file, err := fm.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines, err := file.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := lines[len(lines)]; got != want {
+			t.Errorf("%s: last line %q, want %q", path, got, want)
+		}
+		if result := file.Write("changed\n"); !errors.Is(result.Error, ErrVirtualFile) {
+			t.Errorf("%s: Write returned %v, want ErrVirtualFile", path, result.Error)
+		}
+	}
+	found, err := fm.Find(`x\.json`, 0, false, nil, nil)
+	if err != nil || len(found) != 0 {
+		t.Errorf("Find descended into the archive without WithArchives: %v, %v", found, err)
+	}
+	found, err = fm.Find(`x\.json`, 0, false, nil, nil, WithArchives(true))
+	if err != nil || len(found) != 1 || found[0] != filepath.Join("fixtures.zip!", "data", "x.json") {
+		t.Errorf("Find did not descend into the archive: %v, %v", found, err)
+	}
+}
+
+func TestCorruptArchiveIsListedAsFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "a\n")
+	writeFile(t, filepath.Join(dir, "notreally.zip"), "plain text\n")
+	fm := NewFileManager(dir)
+
+	entries, err := fm.ListFiles("", -1, WithArchives(true))
+	if err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+	want := []FileEntry{{Path: "a.txt", Type: "file", Size: 2}, {Path: "notreally.zip", Type: "file", Size: 11}}
+	if !slices.Equal(entries, want) {
+		t.Errorf("ListFiles = %v, want %v", entries, want)
+	}
+	found, err := fm.Find(`notreally`, 0, false, nil, nil, WithArchives(true))
+	if err != nil || !slices.Equal(found, []string{"notreally.zip"}) {
+		t.Errorf("Find = %v, %v; want only the archive itself", found, err)
+	}
+}
+
+func TestStatDoesNotDecompress(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt.gz")
+	if err := os.WriteFile(path, gzipped(t, "hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fsys := NewArchiveFS(OSFS{})
+	info, err := fsys.Stat(path)
+	if err != nil || info.IsDir() {
+		t.Fatalf("Stat = %v, %v", info, err)
+	}
+	if len(fsys.cache) != 0 {
+		t.Errorf("Stat decompressed the file before its size was asked for")
+	}
+	if info.Size() != int64(len("hello\n")) {
+		t.Errorf("Size = %d, want the decompressed size", info.Size())
+	}
+}
+
+func TestCompressedExtensionWithoutCompressedContent(t *testing.T) {
+	dir := t.TempDir()
+	fm := NewFileManager(dir)
+	// A new file is not compressed yet, so it can be created and written.
+	file, err := fm.Create("new.gz")
+	if err != nil {
+		t.Fatalf("Create(new.gz): %v", err)
+	}
+	info, err := fm.FS().Stat(file.Path)
+	if err != nil || info.Size() != 0 {
+		t.Errorf("Stat of an empty .gz = %v, %v; want its on-disk stat", info, err)
+	}
+	writeFile(t, filepath.Join(dir, "corrupt.gz"), "not gzip\n")
+	data, err := fm.FS().ReadFile(filepath.Join(dir, "corrupt.gz"))
+	if err != nil || string(data) != "not gzip\n" {
+		t.Errorf("ReadFile of a corrupt .gz = %q, %v; want it as it is", data, err)
+	}
+}
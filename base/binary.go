@@ -0,0 +1,169 @@
+package base
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrBinaryFile is returned by line-based operations on files that do not
+// look like text.
+var ErrBinaryFile = errors.New("binary file")
+
+// binarySniffLen is how much of a file is examined to tell text from binary.
+const binarySniffLen = 8192
+
+// hexRowWidth is the number of bytes shown on each row of a hex dump. The
+// window of a binary file counts rows instead of lines.
+const hexRowWidth = 16
+
+// looksBinary tells binary data from text by its first bytes: NUL bytes,
+// a content type http.DetectContentType recognizes as non-text, or a high
+// ratio of control characters and invalid UTF-8 all make it binary. UTF-16
+// text must be recognized before calling it, since it is full of NULs.
+func looksBinary(sniff []byte) (binary bool, contentType string) {
+	contentType = http.DetectContentType(sniff)
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return true, contentType
+	}
+	if !strings.HasPrefix(contentType, "text/") && contentType != "application/octet-stream" {
+		return true, contentType
+	}
+	var control, invalid int
+	for i := 0; i < len(sniff); {
+		r, size := utf8.DecodeRune(sniff[i:])
+		switch {
+		case r == utf8.RuneError && size == 1 && len(sniff)-i >= utf8.UTFMax:
+			invalid++
+		case r < 0x20 && !strings.ContainsRune("\t\n\r\f\v\b\x1b", r):
+			control++
+		}
+		i += size
+	}
+	// Latin-1 text has the odd invalid byte among mostly ASCII; binary data
+	// is dense with them.
+	return control*10 > len(sniff) || invalid*10 > len(sniff)*3, contentType
+}
+
+// checkText fails with ErrBinaryFile when format is that of a binary file.
+func (f *File) checkText(format textFormat) error {
+	if format.encoding == EncodingBinary {
+		return fmt.Errorf("%w: %s cannot be edited as text", ErrBinaryFile, f.Path)
+	}
+	return nil
+}
+
+// checkSearchable fails with ErrBinaryFile when format is that of a binary
+// file, whose bytes would otherwise be matched and returned as lines.
+func (f *File) checkSearchable(format textFormat) error {
+	if format.encoding == EncodingBinary {
+		return fmt.Errorf("%w: %s cannot be searched as text", ErrBinaryFile, f.Path)
+	}
+	return nil
+}
+
+// sniff reads the start of the file and reports whether it is binary.
+func (f *File) sniff() (binary bool, contentType string, err error) {
+	file, err := f.filesystem().Open(f.Path)
+	if err != nil {
+		return false, "", err
+	}
+	defer file.Close()
+	head := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return false, "", err
+	}
+	head = head[:n]
+	if name, _, _ := detectEncoding(head); name != EncodingBinary {
+		return false, http.DetectContentType(head), nil
+	}
+	binary, contentType = looksBinary(head)
+	return binary, contentType, nil
+}
+
+// IsBinary reports whether the file looks like binary data rather than text,
+// and the content type detected for it.
+func (f *File) IsBinary() (bool, string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.sniff()
+}
+
+// GotoOffset moves the window of a binary file to the row holding the byte
+// at offset.
+func (f *File) GotoOffset(offset int) {
+	f.Goto(offset / hexRowWidth)
+}
+
+// readHex returns a hex and ASCII dump of rows [start, end) of the file,
+// keyed by the byte offset each row starts at.
+func (f *File) readHex(start int, end int) (map[int]string, error) {
+	start = max(start, 0)
+	offset := start * hexRowWidth
+	data, err := f.readAt(int64(offset), max(end-start, 0)*hexRowWidth)
+	if err != nil {
+		return nil, err
+	}
+	rows := make(map[int]string)
+	for i := 0; i < len(data); i += hexRowWidth {
+		rows[offset+i] = hexRow(data[i:min(i+hexRowWidth, len(data))])
+	}
+	return rows, nil
+}
+
+// readAt reads up to n bytes of the file from offset, seeking when the
+// filesystem supports it. It returns fewer bytes at the end of the file.
+func (f *File) readAt(offset int64, n int) ([]byte, error) {
+	file, err := f.filesystem().Open(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data := make([]byte, n)
+	switch random := file.(type) {
+	case io.ReaderAt:
+		n, err = random.ReadAt(data, offset)
+	case io.Seeker:
+		if _, err = random.Seek(offset, io.SeekStart); err == nil {
+			n, err = io.ReadFull(file, data)
+		}
+	default:
+		if _, err = io.CopyN(io.Discard, file, offset); err == nil {
+			n, err = io.ReadFull(file, data)
+		}
+	}
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// hexRow formats up to hexRowWidth bytes like "hexdump -C" does, without the
+// offset.
+func hexRow(row []byte) string {
+	var out strings.Builder
+	for i := 0; i < hexRowWidth; i++ {
+		if i == hexRowWidth/2 {
+			out.WriteByte(' ')
+		}
+		if i < len(row) {
+			fmt.Fprintf(&out, "%02x ", row[i])
+		} else {
+			out.WriteString("   ")
+		}
+	}
+	out.WriteString(" |")
+	for _, b := range row {
+		if b < 0x20 || b > 0x7e {
+			b = '.'
+		}
+		out.WriteByte(b)
+	}
+	out.WriteByte('|')
+	return out.String()
+}
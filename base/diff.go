@@ -0,0 +1,188 @@
+package base
+
+import (
+	"fmt"
+	"strings"
+)
+
+type diffOp struct {
+	kind    byte // ' ' for unchanged, '-' for removed, '+' for inserted
+	oldLine int  // 0-based index into the old lines, valid for ' ' and '-'
+	newLine int  // 0-based index into the new lines, valid for ' ' and '+'
+	text    string
+}
+
+// diffLines computes a shortest edit script turning a into b with the
+// linear-space variant of Myers' algorithm, which finds the middle snake of
+// the edit path and recurses on both halves, so memory stays O(len(a)+len(b))
+// however different the texts are.
+func diffLines(a []string, b []string) []diffOp {
+	d := &differ{a: a, b: b, ops: make([]diffOp, 0, max(len(a), len(b)))}
+	d.diff(0, len(a), 0, len(b))
+	return d.ops
+}
+
+type differ struct {
+	a   []string
+	b   []string
+	ops []diffOp
+	// vf and vb hold the furthest reaching forward and backward paths of
+	// middleSnake, reused across calls.
+	vf []int
+	vb []int
+}
+
+// diff appends the edit script turning a[a0:a1] into b[b0:b1].
+func (d *differ) diff(a0 int, a1 int, b0 int, b1 int) {
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		d.ops = append(d.ops, diffOp{kind: ' ', oldLine: a0, newLine: b0, text: d.a[a0]})
+		a0++
+		b0++
+	}
+	suffix := 0
+	for a0 < a1-suffix && b0 < b1-suffix && d.a[a1-1-suffix] == d.b[b1-1-suffix] {
+		suffix++
+	}
+	a1, b1 = a1-suffix, b1-suffix
+	switch {
+	case a0 == a1:
+		for y := b0; y < b1; y++ {
+			d.ops = append(d.ops, diffOp{kind: '+', oldLine: a0, newLine: y, text: d.b[y]})
+		}
+	case b0 == b1:
+		for x := a0; x < a1; x++ {
+			d.ops = append(d.ops, diffOp{kind: '-', oldLine: x, newLine: b0, text: d.a[x]})
+		}
+	default:
+		x, y, u, v := d.middleSnake(a0, a1, b0, b1)
+		d.diff(a0, x, b0, y)
+		for ; x < u; x, y = x+1, y+1 {
+			d.ops = append(d.ops, diffOp{kind: ' ', oldLine: x, newLine: y, text: d.a[x]})
+		}
+		d.diff(u, a1, v, b1)
+	}
+	for i := 0; i < suffix; i++ {
+		d.ops = append(d.ops, diffOp{kind: ' ', oldLine: a1 + i, newLine: b1 + i, text: d.a[a1+i]})
+	}
+}
+
+// middleSnake returns the snake (x, y)-(u, v) in the middle of a shortest
+// edit path from (a0, b0) to (a1, b1), searching forward from the start and
+// backward from the end until the two searches overlap. Both ranges must be
+// non-empty and differ in their first and last lines.
+func (d *differ) middleSnake(a0 int, a1 int, b0 int, b1 int) (x int, y int, u int, v int) {
+	n, m := a1-a0, b1-b0
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	if size := 2*maxD + 3; len(d.vf) < size {
+		d.vf, d.vb = make([]int, size), make([]int, size)
+	}
+	vf, vb := d.vf, d.vb
+	vf[offset+1], vb[offset+1] = 0, 0
+	for step := 0; step <= maxD; step++ {
+		for k := -step; k <= step; k += 2 {
+			var fx int
+			if k == -step || (k != step && vf[offset+k-1] < vf[offset+k+1]) {
+				fx = vf[offset+k+1]
+			} else {
+				fx = vf[offset+k-1] + 1
+			}
+			fy := fx - k
+			sx, sy := fx, fy
+			for fx < n && fy < m && d.a[a0+fx] == d.b[b0+fy] {
+				fx++
+				fy++
+			}
+			vf[offset+k] = fx
+			// Diagonal k forward is diagonal delta-k backward.
+			if back := delta - k; odd && back >= -(step-1) && back <= step-1 && fx+vb[offset+back] >= n {
+				return a0 + sx, b0 + sy, a0 + fx, b0 + fy
+			}
+		}
+		for k := -step; k <= step; k += 2 {
+			var bx int
+			if k == -step || (k != step && vb[offset+k-1] < vb[offset+k+1]) {
+				bx = vb[offset+k+1]
+			} else {
+				bx = vb[offset+k-1] + 1
+			}
+			by := bx - k
+			sx, sy := bx, by
+			for bx < n && by < m && d.a[a1-1-bx] == d.b[b1-1-by] {
+				bx++
+				by++
+			}
+			vb[offset+k] = bx
+			if forward := delta - k; !odd && forward >= -step && forward <= step && vf[offset+forward]+bx >= n {
+				return a1 - bx, b1 - by, a1 - sx, b1 - sy
+			}
+		}
+	}
+	// Unreachable: the searches always meet within maxD steps.
+	return a0, b0, a0, b0
+}
+
+// UnifiedDiff renders the change from oldText to newText as a unified diff
+// with the given number of context lines. It returns "" when the texts are
+// equal.
+func UnifiedDiff(oldName string, newName string, oldText string, newText string, context int) string {
+	if oldText == newText {
+		return ""
+	}
+	a, b := strings.SplitAfter(oldText, "\n"), strings.SplitAfter(newText, "\n")
+	if a[len(a)-1] == "" {
+		a = a[:len(a)-1]
+	}
+	if b[len(b)-1] == "" {
+		b = b[:len(b)-1]
+	}
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// Extend the hunk while the next change is within 2*context lines.
+		start := max(0, i-context)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		end = min(len(ops), end+context)
+
+		oldStart, newStart, oldCount, newCount := ops[start].oldLine+1, ops[start].newLine+1, 0, 0
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			if !strings.HasSuffix(op.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.String()
+}
@@ -0,0 +1,33 @@
+package base
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		context int
+		want    string
+	}{
+		{"equal", "a\n", "a\n", 3, ""},
+		{"from empty", "", "a\nb\n", 3, "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"to empty", "a\nb\n", "", 3, "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"no trailing newline", "a\nb", "a\nc", 3,
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n"},
+		{"trailing newline added", "a", "a\n", 3,
+			"--- a\n+++ b\n@@ -1,1 +1,1 @@\n-a\n\\ No newline at end of file\n+a\n"},
+		{"context 0", "1\n2\n3\n4\n5\n", "1\nX\n3\n4\nY\n", 0,
+			"--- a\n+++ b\n@@ -2,1 +2,1 @@\n-2\n+X\n@@ -5,1 +5,1 @@\n-5\n+Y\n"},
+		{"context 1 joins close changes", "1\n2\n3\n4\n5\n", "1\nX\n3\nY\n5\n", 1,
+			"--- a\n+++ b\n@@ -1,5 +1,5 @@\n 1\n-2\n+X\n 3\n-4\n+Y\n 5\n"},
+		{"context 1 splits distant changes", "1\n2\n3\n4\n5\n6\n7\n", "X\n2\n3\n4\n5\n6\nY\n", 1,
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n-1\n+X\n 2\n@@ -6,2 +6,2 @@\n 6\n-7\n+Y\n"},
+		{"insertion", "a\nc\n", "a\nb\nc\n", 3, "--- a\n+++ b\n@@ -1,2 +1,3 @@\n a\n+b\n c\n"},
+	}
+	for _, test := range tests {
+		if got := UnifiedDiff("a", "b", test.old, test.new, test.context); got != test.want {
+			t.Errorf("%s: UnifiedDiff =\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
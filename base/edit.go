@@ -0,0 +1,160 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type EditMode string
+
+const (
+	EditReplace      EditMode = "replace"
+	EditInsertBefore EditMode = "insert_before"
+	EditInsertAfter  EditMode = "insert_after"
+	EditAppend       EditMode = "append"
+	EditDelete       EditMode = "delete"
+)
+
+var (
+	ErrLineOutOfRange = errors.New("line out of range")
+	ErrInvertedRange  = errors.New("start line is after end line")
+	ErrUnknownMode    = errors.New("unknown edit mode")
+	// ErrOverlappingEdits is returned when two edits of a MultiEdit touch the
+	// same lines.
+	ErrOverlappingEdits = errors.New("edits overlap")
+)
+
+// LineRangeError is returned when an edit addresses lines the file does not
+// have. It wraps ErrLineOutOfRange, ErrInvertedRange or ErrUnknownMode.
+type LineRangeError struct {
+	Mode       EditMode
+	Start      int
+	End        int
+	TotalLines int
+	Err        error
+	Detail     string
+}
+
+func (e *LineRangeError) Error() string {
+	message := fmt.Sprintf("%v: %s %d-%d in a file with %d lines", e.Err, e.Mode, e.Start, e.End, e.TotalLines)
+	if e.Detail != "" {
+		message += " (" + e.Detail + ")"
+	}
+	return message
+}
+
+func (e *LineRangeError) Unwrap() error {
+	return e.Err
+}
+
+// LineEdit is one change to a file addressed by 1-based, inclusive line
+// numbers:
+//   - EditReplace replaces lines Start..End with Text.
+//   - EditInsertBefore inserts Text before line Start; Start may be one past
+//     the last line to insert at the end.
+//   - EditInsertAfter inserts Text after line Start; 0 inserts at the top.
+//   - EditAppend adds Text at the end of the file and ignores Start and End.
+//   - EditDelete removes lines Start..End and ignores Text.
+//
+// Text is split into lines; a single trailing newline is ignored.
+//
+// ExpectedText and Fingerprint guard against editing content that changed
+// since it was viewed: when set, lines Start..End of a replace or delete must
+// still hold ExpectedText, and the file must still have the fingerprint hash returned by
+// File.Fingerprint. Otherwise the edit fails with a *StaleContentError.
+type LineEdit struct {
+	Mode         EditMode
+	Start        int
+	End          int
+	Text         string
+	ExpectedText string
+	Fingerprint  string
+}
+
+// splice is a resolved LineEdit: lines [from, to) are replaced with lines.
+type splice struct {
+	from  int
+	to    int
+	lines []string
+	text  string
+}
+
+// resolve validates the edit against a file of total lines.
+func (e LineEdit) resolve(total int) (splice, error) {
+	mode := e.Mode
+	if mode == "" {
+		mode = EditReplace
+	}
+	fail := func(err error, detail string) (splice, error) {
+		return splice{}, &LineRangeError{Mode: mode, Start: e.Start, End: e.End, TotalLines: total, Err: err, Detail: detail}
+	}
+	checkRange := func() error {
+		if e.Start > e.End {
+			_, err := fail(ErrInvertedRange, "")
+			return err
+		}
+		if e.Start < 1 || e.End > total {
+			_, err := fail(ErrLineOutOfRange, fmt.Sprintf("valid lines are 1-%d", total))
+			return err
+		}
+		return nil
+	}
+
+	switch mode {
+	case EditReplace:
+		// Replacing the first line of an empty file is how a new file gets
+		// its content, so treat it as an append.
+		if total == 0 && e.Start == 1 && e.End <= 1 {
+			return splice{from: 0, to: 0, lines: textLines(e.Text), text: e.Text}, nil
+		}
+		if err := checkRange(); err != nil {
+			return splice{}, err
+		}
+		return splice{from: e.Start - 1, to: e.End, lines: textLines(e.Text), text: e.Text}, nil
+	case EditDelete:
+		if err := checkRange(); err != nil {
+			return splice{}, err
+		}
+		return splice{from: e.Start - 1, to: e.End}, nil
+	case EditInsertBefore:
+		if e.Start < 1 || e.Start > total+1 {
+			return fail(ErrLineOutOfRange, fmt.Sprintf("can insert before lines 1-%d", total+1))
+		}
+		return splice{from: e.Start - 1, to: e.Start - 1, lines: textLines(e.Text), text: e.Text}, nil
+	case EditInsertAfter:
+		if e.Start < 0 || e.Start > total {
+			return fail(ErrLineOutOfRange, fmt.Sprintf("can insert after lines 0-%d", total))
+		}
+		return splice{from: e.Start, to: e.Start, lines: textLines(e.Text), text: e.Text}, nil
+	case EditAppend:
+		return splice{from: total, to: total, lines: textLines(e.Text), text: e.Text}, nil
+	}
+	return fail(ErrUnknownMode, "use replace, insert_before, insert_after, append or delete")
+}
+
+// splitLines splits content into lines without their terminators and reports
+// whether the last line ended with a newline.
+func splitLines(content string) (lines []string, finalNewline bool) {
+	if content == "" {
+		return []string{}, false
+	}
+	finalNewline = strings.HasSuffix(content, "\n")
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n"), finalNewline
+}
+
+func joinLines(lines []string, finalNewline bool) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	joined := strings.Join(lines, "\n")
+	if finalNewline {
+		joined += "\n"
+	}
+	return joined
+}
+
+// textLines splits replacement text into lines, ignoring one trailing newline.
+func textLines(text string) []string {
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,141 @@
+package base
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestEditModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		edits   []LineEdit // applied one after the other
+		want    string
+		total   int
+	}{
+		{"replace", "a\nb\nc\n", []LineEdit{{Mode: EditReplace, Start: 2, End: 3, Text: "B\n"}}, "a\nB\n", 2},
+		{"default mode replaces", "a\nb\nc\n", []LineEdit{{Start: 1, End: 1, Text: "A"}}, "A\nb\nc\n", 3},
+		{"insert_before", "a\nb\n", []LineEdit{{Mode: EditInsertBefore, Start: 2, Text: "x\ny"}}, "a\nx\ny\nb\n", 4},
+		{"insert_before past the end", "a\nb\n", []LineEdit{{Mode: EditInsertBefore, Start: 3, Text: "c"}}, "a\nb\nc\n", 3},
+		{"insert_after", "a\nb\n", []LineEdit{{Mode: EditInsertAfter, Start: 1, Text: "x"}}, "a\nx\nb\n", 3},
+		{"insert_after 0", "a\nb\n", []LineEdit{{Mode: EditInsertAfter, Start: 0, Text: "top"}}, "top\na\nb\n", 3},
+		{"append", "a\nb\n", []LineEdit{{Mode: EditAppend, Text: "c"}}, "a\nb\nc\n", 3},
+		{"append without final newline", "a\nb", []LineEdit{{Mode: EditAppend, Text: "c"}}, "a\nb\nc", 3},
+		{"delete", "a\nb\nc\n", []LineEdit{{Mode: EditDelete, Start: 2, End: 2}}, "a\nc\n", 2},
+		{"delete every line, then replace line 1", "a\nb\nc\n", []LineEdit{
+			{Mode: EditDelete, Start: 1, End: 3},
+			{Mode: EditReplace, Start: 1, End: 1, Text: "new"},
+		}, "new\n", 1},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "a.txt")
+		writeFile(t, path, test.content)
+		file, err := NewFileManager(dir).Open("a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result TextReplacement
+		for _, edit := range test.edits {
+			if result = file.ApplyEdit(edit, ScopeFile); result.Error != nil {
+				t.Fatalf("%s: %v", test.name, result.Error)
+			}
+		}
+		if got := onDisk(t, path); got != test.want {
+			t.Errorf("%s: file = %q, want %q", test.name, got, test.want)
+		}
+		if result.TotalLines != test.total {
+			t.Errorf("%s: TotalLines = %d, want %d", test.name, result.TotalLines, test.total)
+		}
+	}
+}
+
+func TestEditRangeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		edit LineEdit
+		want error
+	}{
+		{"start after end", LineEdit{Mode: EditReplace, Start: 3, End: 2, Text: "x"}, ErrInvertedRange},
+		{"delete start after end", LineEdit{Mode: EditDelete, Start: 2, End: 1}, ErrInvertedRange},
+		{"start past the end", LineEdit{Mode: EditReplace, Start: 4, End: 4, Text: "x"}, ErrLineOutOfRange},
+		{"start 0", LineEdit{Mode: EditReplace, Start: 0, End: 1, Text: "x"}, ErrLineOutOfRange},
+		{"insert_before too far", LineEdit{Mode: EditInsertBefore, Start: 5, Text: "x"}, ErrLineOutOfRange},
+		{"insert_after past the end", LineEdit{Mode: EditInsertAfter, Start: 4, Text: "x"}, ErrLineOutOfRange},
+		{"unknown mode", LineEdit{Mode: "upsert", Start: 1, End: 1}, ErrUnknownMode},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "a.txt")
+		writeFile(t, path, "a\nb\nc\n")
+		file, err := NewFileManager(dir).Open("a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := file.ApplyEdit(test.edit, ScopeFile)
+		if !errors.Is(result.Error, test.want) {
+			t.Errorf("%s: error %v, want %v", test.name, result.Error, test.want)
+		}
+		var rangeErr *LineRangeError
+		if !errors.As(result.Error, &rangeErr) || rangeErr.TotalLines != 3 {
+			t.Errorf("%s: error %#v, want a *LineRangeError for 3 lines", test.name, result.Error)
+		}
+		if result.TotalLines != 3 {
+			t.Errorf("%s: TotalLines = %d, want 3", test.name, result.TotalLines)
+		}
+		if got := onDisk(t, path); got != "a\nb\nc\n" {
+			t.Errorf("%s: file = %q, want it unchanged", test.name, got)
+		}
+	}
+}
+
+func TestMultiEditOverlap(t *testing.T) {
+	tests := []struct {
+		name  string
+		edits []LineEdit
+		want  string // "" when the edits must be rejected
+	}{
+		{"overlapping", []LineEdit{
+			{Mode: EditReplace, Start: 2, End: 3, Text: "X"},
+			{Mode: EditInsertAfter, Start: 2, Text: "Y"},
+		}, ""},
+		{"same line replaced twice", []LineEdit{
+			{Mode: EditReplace, Start: 2, End: 2, Text: "X"},
+			{Mode: EditDelete, Start: 1, End: 2},
+		}, ""},
+		{"touching", []LineEdit{
+			{Mode: EditReplace, Start: 2, End: 3, Text: "X"},
+			{Mode: EditInsertBefore, Start: 2, Text: "Y"},
+		}, "a\nY\nX\nd\n"},
+		{"adjacent", []LineEdit{
+			{Mode: EditReplace, Start: 3, End: 3, Text: "C"},
+			{Mode: EditReplace, Start: 1, End: 2, Text: "AB"},
+		}, "AB\nC\nd\n"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "a.txt")
+		writeFile(t, path, "a\nb\nc\nd\n")
+		file, err := NewFileManager(dir).Open("a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := file.MultiEdit(test.edits, ScopeFile)
+		if test.want == "" {
+			if !errors.Is(result.Error, ErrOverlappingEdits) {
+				t.Errorf("%s: error %v, want ErrOverlappingEdits", test.name, result.Error)
+			}
+			if got := onDisk(t, path); got != "a\nb\nc\nd\n" {
+				t.Errorf("%s: file = %q, want it unchanged", test.name, got)
+			}
+			continue
+		}
+		if result.Error != nil {
+			t.Errorf("%s: %v", test.name, result.Error)
+		}
+		if got := onDisk(t, path); got != test.want {
+			t.Errorf("%s: file = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package base
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Names of the encodings File detects.
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingLatin1  = "iso-8859-1"
+	// EncodingBinary marks content that does not look like text; it is
+	// passed through unchanged.
+	EncodingBinary = "binary"
+)
+
+// encodingSniffLen is how much of a file the UTF-16 heuristic looks at.
+const encodingSniffLen = 4096
+
+var (
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// detectEncoding guesses the encoding of raw from its byte order mark or,
+// without one, from its bytes: UTF-16 text without a BOM has a NUL in every
+// other byte, data looksBinary rejects is binary, valid UTF-8 is UTF-8 and
+// the rest is taken as Latin-1, which decodes any byte sequence. It returns the
+// BOM found, if any, and a nil encoding for UTF-8 and binary data.
+func detectEncoding(raw []byte) (name string, enc encoding.Encoding, bom []byte) {
+	switch {
+	case bytes.HasPrefix(raw, utf8BOM):
+		return EncodingUTF8, nil, utf8BOM
+	case bytes.HasPrefix(raw, utf16LEBOM):
+		return EncodingUTF16LE, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), utf16LEBOM
+	case bytes.HasPrefix(raw, utf16BEBOM):
+		return EncodingUTF16BE, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), utf16BEBOM
+	}
+
+	sniff := raw[:min(len(raw), encodingSniffLen)]
+	var evenNUL, oddNUL int
+	for i, b := range sniff {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenNUL++
+		} else {
+			oddNUL++
+		}
+	}
+	// Mostly-ASCII UTF-16 has a NUL in nearly every high byte and almost none
+	// in the low bytes.
+	pairs := len(sniff) / 2
+	switch {
+	case pairs > 0 && oddNUL > pairs*2/5 && evenNUL <= pairs/20:
+		return EncodingUTF16LE, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), nil
+	case pairs > 0 && evenNUL > pairs*2/5 && oddNUL <= pairs/20:
+		return EncodingUTF16BE, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), nil
+	}
+	if binary, _ := looksBinary(raw[:min(len(raw), binarySniffLen)]); binary {
+		return EncodingBinary, nil, nil
+	}
+	if utf8.Valid(raw) {
+		return EncodingUTF8, nil, nil
+	}
+	return EncodingLatin1, charmap.ISO8859_1, nil
+}
+
+// trimPartialRune drops a UTF-8 character cut off at the end of sample, the
+// first bytes of a longer file, so that a sample of UTF-8 text still
+// validates as UTF-8.
+func trimPartialRune(sample []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(sample); i++ {
+		if start := len(sample) - i; utf8.RuneStart(sample[start]) {
+			if !utf8.FullRune(sample[start:]) {
+				return sample[:start]
+			}
+			break
+		}
+	}
+	return sample
+}
+
+// DecodeText converts raw file data to UTF-8 text and reports the encoding
+// it was in. ok is false for data that looks binary.
+func DecodeText(raw []byte) (text string, name string, ok bool) {
+	name, enc, bom := detectEncoding(raw)
+	if name == EncodingBinary {
+		return "", name, false
+	}
+	raw = raw[len(bom):]
+	if enc == nil {
+		return string(raw), name, true
+	}
+	decoded, err := enc.NewDecoder().Bytes(raw)
+	if err != nil {
+		return "", name, false
+	}
+	return string(decoded), name, true
+}
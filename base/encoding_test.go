@@ -0,0 +1,74 @@
+package base
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+)
+
+// encodeUTF16 returns text as UTF-16 in byte order order, after a BOM.
+func encodeUTF16(text string, order binary.AppendByteOrder) []byte {
+	data := order.AppendUint16(nil, 0xFEFF)
+	for _, unit := range utf16.Encode([]rune(text)) {
+		data = order.AppendUint16(data, unit)
+	}
+	return data
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		before   []byte
+		after    []byte
+	}{
+		{"latin-1", EncodingLatin1, []byte("caf\xe9\nna\xefve\n"), []byte("caf\xe9\nr\xe9sum\xe9\n")},
+		{"utf-16le", EncodingUTF16LE, encodeUTF16("café\nnaïve\n", binary.LittleEndian), encodeUTF16("café\nrésumé\n", binary.LittleEndian)},
+		{"utf-16be", EncodingUTF16BE, encodeUTF16("café\nnaïve\n", binary.BigEndian), encodeUTF16("café\nrésumé\n", binary.BigEndian)},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "a.txt")
+		if err := os.WriteFile(path, test.before, 0644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := NewFileManager(dir).Open("a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines, err := file.Read(); err != nil || lines[1] != "café" || lines[2] != "naïve" {
+			t.Errorf("%s: Read = %q, %v", test.name, lines, err)
+		}
+		result := file.Edit("résumé", 2, 2, ScopeFile)
+		if result.Error != nil {
+			t.Fatalf("%s: %v", test.name, result.Error)
+		}
+		if result.Encoding != test.encoding {
+			t.Errorf("%s: Encoding = %q, want %q", test.name, result.Encoding, test.encoding)
+		}
+		if data, err := os.ReadFile(path); err != nil || string(data) != string(test.after) {
+			t.Errorf("%s: file after the edit = %q, %v; want %q", test.name, data, err, test.after)
+		}
+	}
+}
+
+func TestEncodingRejectsUnrepresentableText(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	before := []byte("caf\xe9\nprice\n")
+	if err := os.WriteFile(path, before, 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := NewFileManager(dir).Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := file.Edit("5 €", 2, 2, ScopeFile); result.Error == nil {
+		t.Error("an edit with a character Latin-1 cannot hold succeeded")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != string(before) {
+		t.Errorf("file after the refused edit = %q, %v; want it unchanged", data, err)
+	}
+}
@@ -0,0 +1,143 @@
+package base
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"golang.org/x/text/encoding"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// textFormat is the on-disk convention of a text file. Edits work on UTF-8
+// content with "\n" line endings and no BOM, and writes restore the
+// convention.
+type textFormat struct {
+	encoding string
+	enc      encoding.Encoding
+	bom      []byte
+	crlf     bool
+	mode     fs.FileMode
+	// fingerprint is the fingerprint of the data the content was read from.
+	fingerprint Fingerprint
+}
+
+// decodeText detects the convention of raw and converts it to content. A
+// file uses CRLF when most of its line endings are CRLF; files with a few
+// stray "\r" are left alone.
+func decodeText(raw []byte) (string, textFormat, error) {
+	format := textFormat{mode: 0644}
+	format.encoding, format.enc, format.bom = detectEncoding(raw)
+	content, err := format.decode(raw[len(format.bom):])
+	if err != nil {
+		return "", format, err
+	}
+	crlf := strings.Count(content, "\r\n")
+	lf := strings.Count(content, "\n") - crlf
+	if crlf > 0 && crlf >= lf {
+		format.crlf = true
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+	}
+	return content, format, nil
+}
+
+// decode converts data in the encoding of t to UTF-8, leaving line endings
+// alone.
+func (t textFormat) decode(data []byte) (string, error) {
+	if t.enc == nil {
+		return string(data), nil
+	}
+	decoded, err := t.enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("could not decode %s: %v", t.encoding, err)
+	}
+	return string(decoded), nil
+}
+
+// encode turns content back into file data in the convention of t.
+func (t textFormat) encode(content string) ([]byte, error) {
+	if t.crlf {
+		content = strings.ReplaceAll(strings.ReplaceAll(content, "\r\n", "\n"), "\n", "\r\n")
+	}
+	data := []byte(content)
+	if t.enc != nil {
+		encoded, err := t.enc.NewEncoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("the text cannot be written as %s: %v", t.encoding, err)
+		}
+		data = encoded
+	}
+	if len(t.bom) > 0 {
+		data = append(append([]byte{}, t.bom...), data...)
+	}
+	return data, nil
+}
+
+// readText returns the file as UTF-8 content with "\n" line endings along
+// with its convention and permission bits. The line index is rebuilt from
+// the data read when it does not describe it.
+func (f *File) readText() (string, textFormat, error) {
+	info, err := f.filesystem().Stat(f.Path)
+	if err != nil {
+		return "", textFormat{mode: 0644}, err
+	}
+	raw, err := f.filesystem().ReadFile(f.Path)
+	if err != nil {
+		return "", textFormat{mode: 0644}, err
+	}
+	content, format, err := decodeText(raw)
+	if err != nil {
+		return "", format, err
+	}
+	format.mode = info.Mode().Perm()
+	format.fingerprint = Fingerprint{Hash: contentHash(raw), Size: int64(len(raw)), ModTime: info.ModTime()}
+	if cached := f.index.Load(); cached == nil || cached.format.fingerprint.Hash != format.fingerprint.Hash {
+		if int64(len(raw)) == info.Size() {
+			f.index.Store(newLineIndex(raw, format))
+		}
+	}
+	return content, format, nil
+}
+
+// writeText writes content, edited from original, in the convention read by
+// readText. The line index is updated from the edit, so the file is neither
+// read back nor rescanned.
+func (f *File) writeText(original string, content string, format textFormat) error {
+	data, err := format.encode(content)
+	if err != nil {
+		return err
+	}
+	cached := f.index.Load()
+	if err := f.writeRaw(data, format.mode); err != nil {
+		return err
+	}
+	read := format.fingerprint
+	format.fingerprint = f.Fingerprint()
+	if cached != nil && cached.format.fingerprint.Hash == read.Hash {
+		f.index.Store(cached.edited(original, content, data, format))
+	} else {
+		f.index.Store(newLineIndex(data, format))
+	}
+	return nil
+}
+
+// writeRaw writes data, restores mode, which WriteFile only applies to
+// files it creates, and records the fingerprint of what was written.
+func (f *File) writeRaw(data []byte, mode fs.FileMode) error {
+	f.index.Store(nil)
+	if err := f.filesystem().WriteFile(f.Path, data, mode); err != nil {
+		return err
+	}
+	written := Fingerprint{Hash: contentHash(data), Size: int64(len(data))}
+	defer f.seen.Store(&written)
+	info, err := f.filesystem().Stat(f.Path)
+	if err != nil {
+		return nil
+	}
+	written.ModTime = info.ModTime()
+	if info.Mode().Perm() != mode {
+		return f.filesystem().Chmod(f.Path, mode)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package base
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMutationsPreserveFormat checks that every mutating operation keeps the
+// line endings, BOM, final newline and permissions of the file it changes.
+func TestMutationsPreserveFormat(t *testing.T) {
+	formats := []struct {
+		name    string
+		content string
+		mode    os.FileMode
+	}{
+		{"lf", "one\ntwo\nthree\n", 0644},
+		{"crlf", "one\r\ntwo\r\nthree\r\n", 0644},
+		{"bom", "\ufeffone\ntwo\nthree\n", 0644},
+		{"no final newline", "one\ntwo\nthree", 0644},
+		{"crlf bom without final newline", "\ufeffone\r\ntwo\r\nthree", 0644},
+		{"executable", "one\ntwo\nthree\n", 0755},
+	}
+	operations := []struct {
+		name  string
+		apply func(file *File) TextReplacement
+	}{
+		{"Edit", func(file *File) TextReplacement {
+			return file.Edit("TWO", 2, 2, ScopeFile)
+		}},
+		{"MultiEdit", func(file *File) TextReplacement {
+			return file.MultiEdit([]LineEdit{{Start: 2, End: 2, Text: "TWO"}}, ScopeFile)
+		}},
+		{"Replace", func(file *File) TextReplacement {
+			return file.Replace("two", "TWO")
+		}},
+		{"Write", func(file *File) TextReplacement {
+			return file.Write("one\nTWO\nthree\n")
+		}},
+	}
+	for _, format := range formats {
+		for _, operation := range operations {
+			t.Run(format.name+"/"+operation.name, func(t *testing.T) {
+				dir := t.TempDir()
+				path := filepath.Join(dir, "a.txt")
+				writeFile(t, path, format.content)
+				if err := os.Chmod(path, format.mode); err != nil {
+					t.Fatal(err)
+				}
+				file, err := NewFileManager(dir).Open("a.txt")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result := operation.apply(file); result.Error != nil {
+					t.Fatal(result.Error)
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := strings.Replace(format.content, "two", "TWO", 1); string(data) != want {
+					t.Errorf("content = %q, want %q", data, want)
+				}
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode().Perm() != format.mode {
+					t.Errorf("mode = %v, want %v", info.Mode().Perm(), format.mode)
+				}
+			})
+		}
+	}
+}
@@ -1,11 +1,15 @@
 package base
 
 import (
-	"bufio"
 	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type ScrollDirection string
@@ -31,14 +35,62 @@ type TextReplacement struct {
 	ReplacedWith string
 	ReplacedText string
 	Error        error
+	// Diagnostics introduced by the edit; the edit was reverted when not empty.
+	Diagnostics []Diagnostic
+	// Formatted reports whether the formatter changed the written text.
+	Formatted bool
+	// FinalText is the edited region as it was written, after formatting.
+	FinalText string
+	// Diff is a unified diff of the whole change to the file.
+	Diff string
+	// Context shows the edited region with a few surrounding lines, each
+	// prefixed with its line number.
+	Context string
+	// Warnings point out likely mistakes, such as duplicated adjacent lines
+	// or brackets the edit left unbalanced.
+	Warnings []string
+	// TotalLines is the number of lines in the file after the operation.
+	TotalLines int
+	// Replacements is the number of occurrences Replace changed.
+	Replacements int
+	// Strategy is how Replace found the text, and Similarity how close the
+	// replaced text was to the search text, from 0 to 1.
+	Strategy   MatchStrategy
+	Similarity float64
+	// Encoding is the character encoding the file was read and written in.
+	Encoding string
 }
 
+// File is an open file with a viewing window. All methods are safe for
+// concurrent use: reads share the file lock, while edits and window moves
+// take it exclusively, so two edits to the same file are serialized.
 type File struct {
+	mu      sync.RWMutex
+	fs      FS
 	Path    string
 	Workdir string
 	Start   int
 	End     int
 	Window  int
+	// AutoFormat runs the formatter registered for the file's extension
+	// after every Edit, Write and Replace.
+	AutoFormat bool
+	// MaxLineWidth is the number of characters after which Read cuts a line
+	// and marks it as truncated; 0 means DefaultMaxLineWidth and a negative
+	// width never truncates.
+	MaxLineWidth int
+	// CharBudget caps the number of characters one window shows, whatever
+	// its line count; 0 means no cap. See SetTokenBudget.
+	CharBudget int
+	// seen is the fingerprint of the content last viewed or written.
+	seen atomic.Pointer[Fingerprint]
+	// index caches the line offsets; nil until first read.
+	index atomic.Pointer[lineIndex]
+	// searchFrom is the match FindNext last moved to; nil after the window
+	// is moved otherwise.
+	searchFrom *Match
+	// follow is where Follow continues; nil until Tail or Follow is called.
+	follow *followState
 }
 
 func (sd *ScrollDirection) Offset(lines int) int {
@@ -62,20 +114,99 @@ func NewFile(path string, workdir string, window int) *File {
 	}
 }
 
+// filesystem returns the FS the file lives on, the OS unless the file was
+// opened through a FileManager with another backend.
+func (f *File) filesystem() FS {
+	if f.fs == nil {
+		return defaultFS
+	}
+	return f.fs
+}
+
+// SetAutoFormat turns the post-edit formatter stage on or off.
+func (f *File) SetAutoFormat(enabled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.AutoFormat = enabled
+}
+
+// lineRange is a 0-based, half-open range of lines.
+type lineRange struct {
+	start int
+	end   int
+}
+
+// format runs the file's formatter over content when AutoFormat is set. The
+// edited regions are mapped onto the formatted text. Content the formatter
+// rejects is returned unchanged.
+func (f *File) format(content string, regions []lineRange) (string, []lineRange, bool) {
+	if !f.AutoFormat {
+		return content, regions, false
+	}
+	formatter := FormatterFor(f.Path)
+	if formatter == nil {
+		return content, regions, false
+	}
+	formatted, err := formatter.Format(f.Path, []byte(content))
+	if err != nil || string(formatted) == content {
+		return content, regions, false
+	}
+	before, after := strings.Split(content, "\n"), strings.Split(string(formatted), "\n")
+	ops := diffLines(before, after)
+	mapped := make([]lineRange, len(regions))
+	for i, r := range regions {
+		mapped[i].start, mapped[i].end = mapLineRange(ops, len(after), r.start, r.end)
+	}
+	return string(formatted), mapped, true
+}
+
+// lineCount returns the number of lines in content; a final line without a
+// newline still counts.
+func lineCount(content string) int {
+	lines, _ := splitLines(content)
+	return len(lines)
+}
+
+// region returns the lines of content in each range, with "..." between
+// separate ranges.
+func region(content string, regions []lineRange) string {
+	lines := strings.Split(content, "\n")
+	parts := make([]string, 0, len(regions))
+	for _, r := range regions {
+		start, end := min(max(r.start, 0), len(lines)), min(max(r.end, 0), len(lines))
+		if start < end {
+			parts = append(parts, strings.Join(lines[start:end], "\n"))
+		}
+	}
+	return strings.Join(parts, "\n...\n")
+}
+
 func (f *File) Scroll(lines int, direction ScrollDirection) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	lines = direction.Offset(lines)
 	f.Start += lines
 	f.End += lines
+	f.searchFrom = nil
 }
 
 func (f *File) Goto(line int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.Start = line
 	f.End = line + f.Window
+	f.searchFrom = nil
 }
 
-func (f *File) find(buffer string, pattern string, lineno int) []Match {
+// Bounds returns the current window as a [start, end) line range.
+func (f *File) Bounds() (start int, end int) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.Start, f.End
+}
+
+func (f *File) find(buffer string, re *regexp.Regexp, lineno int) []Match {
 	var matches []Match
-	re := regexp.MustCompile(pattern)
 	for _, match := range re.FindAllStringIndex(buffer, -1) {
 		start, end := match[0], match[1]
 		matches = append(matches, Match{
@@ -89,158 +220,317 @@ func (f *File) find(buffer string, pattern string, lineno int) []Match {
 	return matches
 }
 
-func (f *File) findWindow(pattern string) []Match {
-	offset := f.Start
+func (f *File) findWindow(re *regexp.Regexp) ([]Match, error) {
+	offset := max(f.Start, 0)
+	lines, err := f.iterWindow()
+	if err != nil {
+		return nil, err
+	}
 	var matches []Match
-	for lineno, line := range f.iterWindow() {
-		matches = append(matches, f.find(line, pattern, lineno+offset)...)
+	for i, line := range lines {
+		matches = append(matches, f.find(line, re, offset+i+1)...)
 	}
-	return matches
+	return matches, nil
 }
 
-func (f *File) findFile(pattern string) []Match {
+func (f *File) findFile(re *regexp.Regexp) ([]Match, error) {
+	lines, err := f.iterFile()
+	if err != nil {
+		return nil, err
+	}
 	var matches []Match
-	for lineno, line := range f.iterFile() {
-		matches = append(matches, f.find(line, pattern, lineno)...)
+	for i, line := range lines {
+		matches = append(matches, f.find(line, re, i+1)...)
 	}
-	return matches
+	return matches, nil
 }
 
-func (f *File) Find(pattern string, scope FileOperationScope) []Match {
+// Find returns the matches of the regular expression pattern in the window
+// or the whole file. Lineno is 1-based like the keys of Read, and Start and
+// End are the byte offsets of the match within its line.
+func (f *File) Find(pattern string, scope FileOperationScope) ([]Match, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %v", err)
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	if scope == ScopeFile {
-		return f.findFile(pattern)
+		return f.findFile(re)
 	}
-	return f.findWindow(pattern)
+	return f.findWindow(re)
 }
 
-func (f *File) iterWindow() []string {
-	var lines []string
-	file, _ := os.Open(f.Path)
-	defer func(file *os.File) {
-		err := file.Close()
-		if err != nil {
-		}
-	}(file)
-	scanner := bufio.NewScanner(file)
-	cursor := 0
-	for scanner.Scan() {
-		if cursor >= f.Start && cursor < f.End {
-			lines = append(lines, scanner.Text())
-		}
-		cursor++
+func (f *File) iterWindow() ([]string, error) {
+	ix, err := f.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	if err := f.checkSearchable(ix.format); err != nil {
+		return nil, err
 	}
-	return lines
+	return f.readLines(ix, f.Start, f.End)
 }
 
-func (f *File) iterFile() []string {
-	var lines []string
-	file, _ := os.Open(f.Path)
-	defer func(file *os.File) {
-		err := file.Close()
-		if err != nil {
-		}
-	}(file)
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+func (f *File) iterFile() ([]string, error) {
+	ix, err := f.loadIndex()
+	if err != nil {
+		return nil, err
 	}
-	return lines
+	if err := f.checkSearchable(ix.format); err != nil {
+		return nil, err
+	}
+	return f.readLines(ix, 0, ix.lineCount())
 }
 
-func (f *File) Read() map[int]string {
-	cursor := 0
-	buffer := make(map[int]string)
-	file, _ := os.Open(f.Path)
-	defer func(file *os.File) {
-		err := file.Close()
-		if err != nil {
-		}
-	}(file)
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		if cursor >= f.Start && cursor < f.End {
-			buffer[cursor+1] = scanner.Text()
-		}
-		cursor++
+// Read returns the lines of the current window by 1-based line number and
+// records the file's fingerprint for stale-edit checks. Lines longer than
+// MaxLineWidth are cut and end with a marker giving their full length, and
+// the window stops early when CharBudget runs out; use View to learn where.
+//
+// Binary files are shown as a hex and ASCII dump instead: the window counts
+// rows of 16 bytes, and each row is keyed by the byte offset it starts at.
+func (f *File) Read() (map[int]string, error) {
+	view, err := f.View()
+	if err != nil {
+		return nil, err
 	}
-	return buffer
+	return view.Lines, nil
 }
 
-func (f *File) Write(text string) error {
-	return os.WriteFile(f.Path, []byte(text), 0644)
+// Write replaces the whole file with text. The file keeps its line endings,
+// BOM and permissions, and whether it ends with a newline. Binary files are
+// refused with ErrBinaryFile, like every other edit.
+func (f *File) Write(text string) TextReplacement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	original, format, err := f.readText()
+	if err := f.checkText(format); err != nil {
+		return TextReplacement{Error: err}
+	}
+	if err == nil && original != "" {
+		_, finalNewline := splitLines(original)
+		text = strings.TrimSuffix(text, "\n")
+		if finalNewline {
+			text += "\n"
+		}
+	}
+	updated, regions, formatted := f.format(text, []lineRange{{0, strings.Count(text, "\n") + 1}})
+	if err := f.writeText(original, updated, format); err != nil {
+		return TextReplacement{Error: err}
+	}
+	response := TextReplacement{
+		ReplacedText: original,
+		ReplacedWith: text,
+		Formatted:    formatted,
+		FinalText:    region(updated, regions),
+		TotalLines:   lineCount(updated),
+		Encoding:     format.encoding,
+	}
+	f.annotate(&response, original, updated, regions)
+	return response
 }
 
 func (f *File) TotalLines() int {
-	return len(f.iterFile())
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	ix, err := f.loadIndex()
+	if err != nil {
+		return 0
+	}
+	return ix.lineCount()
 }
 
+// Edit replaces lines start..end (1-based, inclusive) with text.
 func (f *File) Edit(text string, start int, end int, scope FileOperationScope) TextReplacement {
-	originalContent, _ := os.ReadFile(f.Path)
-	content := string(originalContent)
-	lines := strings.Split(content, "\n")
+	return f.ApplyEdit(LineEdit{Mode: EditReplace, Start: start, End: end, Text: text}, scope)
+}
 
-	var buffer strings.Builder
-	replaced := ""
+// ApplyEdit applies a line edit. With ScopeWindow the edit must lie inside
+// the current window. Invalid ranges fail with a *LineRangeError.
+func (f *File) ApplyEdit(edit LineEdit, scope FileOperationScope) TextReplacement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.edit([]LineEdit{edit}, scope)
+}
 
-	if scope == ScopeWindow {
-		start = max(start, f.Start)
-		end = min(end, f.End)
-	}
+// MultiEdit applies several line edits in a single write. Every edit uses the
+// line numbers of the file before any of them is applied, so earlier hunks do
+// not shift later ones; overlapping edits fail with ErrOverlappingEdits.
+func (f *File) MultiEdit(edits []LineEdit, scope FileOperationScope) TextReplacement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.edit(edits, scope)
+}
 
-	for i := 0; i < start-1; i++ {
-		buffer.WriteString(lines[i] + "\n")
+func (f *File) edit(edits []LineEdit, scope FileOperationScope) TextReplacement {
+	if len(edits) == 0 {
+		return TextReplacement{Error: errors.New("no edits given")}
+	}
+	content, format, err := f.readText()
+	if err != nil {
+		return TextReplacement{Error: err}
+	}
+	if err := f.checkText(format); err != nil {
+		return TextReplacement{Error: err}
 	}
-	for i := start - 1; i < end; i++ {
-		replaced += lines[i] + "\n"
+	lines, finalNewline := splitLines(content)
+	if len(lines) == 0 {
+		finalNewline = true
 	}
-	buffer.WriteString(text + "\n")
-	for i := end; i < len(lines); i++ {
-		buffer.WriteString(lines[i] + "\n")
+	if err := f.checkExpected(edits, content, format.fingerprint); err != nil {
+		return TextReplacement{Error: err, TotalLines: len(lines)}
 	}
 
-	err := os.WriteFile(f.Path, []byte(buffer.String()), 0644)
-	if err != nil {
-		return TextReplacement{Error: err}
+	changes := make([]splice, 0, len(edits))
+	for _, edit := range edits {
+		change, err := edit.resolve(len(lines))
+		if err != nil {
+			return TextReplacement{Error: err, TotalLines: len(lines)}
+		}
+		if scope == ScopeWindow && (change.from < f.Start || change.to > f.End) {
+			return TextReplacement{
+				Error: &LineRangeError{
+					Mode:       edit.Mode,
+					Start:      edit.Start,
+					End:        edit.End,
+					TotalLines: len(lines),
+					Err:        ErrLineOutOfRange,
+					Detail:     fmt.Sprintf("outside the current window, lines %d-%d", f.Start+1, f.End),
+				},
+				TotalLines: len(lines),
+			}
+		}
+		changes = append(changes, change)
 	}
-	return TextReplacement{
-		ReplacedText: replaced,
-		ReplacedWith: text,
+	slices.SortStableFunc(changes, func(a, b splice) int {
+		if a.from != b.from {
+			return a.from - b.from
+		}
+		return a.to - b.to
+	})
+	for i := 1; i < len(changes); i++ {
+		if prev, next := changes[i-1], changes[i]; next.from < prev.to {
+			return TextReplacement{
+				Error: fmt.Errorf("%w: lines %d-%d and %d-%d",
+					ErrOverlappingEdits, prev.from+1, prev.to, next.from+1, max(next.to, next.from+1)),
+				TotalLines: len(lines),
+			}
+		}
 	}
-}
 
-func (f *File) Replace(search string, replacement string) TextReplacement {
-	content, _ := os.ReadFile(f.Path)
-	updated := strings.ReplaceAll(string(content), search, replacement)
-	if string(content) == updated {
-		return TextReplacement{
-			ReplacedText: "",
-			ReplacedWith: "",
-			Error:        errors.New("error replacing given string, string not found"),
+	updatedLines := make([]string, 0, len(lines))
+	regions := make([]lineRange, 0, len(changes))
+	replaced := make([]string, 0, len(changes))
+	replacements := make([]string, 0, len(changes))
+	cursor := 0
+	for _, change := range changes {
+		updatedLines = append(updatedLines, lines[cursor:change.from]...)
+		regions = append(regions, lineRange{len(updatedLines), len(updatedLines) + len(change.lines)})
+		updatedLines = append(updatedLines, change.lines...)
+		if change.to > change.from {
+			replaced = append(replaced, joinLines(lines[change.from:change.to], true))
+		}
+		if len(change.lines) > 0 {
+			replacements = append(replacements, change.text)
 		}
+		cursor = change.to
 	}
-	err := os.WriteFile(f.Path, []byte(updated), 0644)
+	updatedLines = append(updatedLines, lines[cursor:]...)
+
+	updated, regions, formatted := f.format(joinLines(updatedLines, finalNewline), regions)
+	err = f.writeText(content, updated, format)
 	if err != nil {
-		return TextReplacement{}
+		return TextReplacement{Error: err, TotalLines: len(lines)}
 	}
-	return TextReplacement{
-		ReplacedText: search,
-		ReplacedWith: replacement,
+	response := TextReplacement{
+		ReplacedText: strings.Join(replaced, "...\n"),
+		ReplacedWith: strings.Join(replacements, "\n...\n"),
+		Formatted:    formatted,
+		FinalText:    region(updated, regions),
+		TotalLines:   lineCount(updated),
+		Encoding:     format.encoding,
 	}
+	f.annotate(&response, content, updated, regions)
+	return response
+}
+
+// WriteAndRunLint applies the edits and runs the linter registered for the
+// file's extension. If the edit introduces diagnostics that were not in the
+// file before, the old content is restored and they are returned.
+func (f *File) WriteAndRunLint(edits ...LineEdit) TextReplacement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lintGuard(func() TextReplacement {
+		return f.edit(edits, ScopeFile)
+	})
 }
 
-func (f *File) WriteAndRunLint(text string, start int, end int) TextReplacement {
-	olderFileText, _ := os.ReadFile(f.Path)
-	writeResponse := f.Edit(text, start, end, ScopeWindow)
+// lintGuard runs apply, which writes the file unless it fails, and reverts
+// the write if it introduced lint diagnostics. The caller must hold f.mu.
+func (f *File) lintGuard(apply func() TextReplacement) TextReplacement {
+	olderFileText, err := f.filesystem().ReadFile(f.Path)
+	if err != nil {
+		return TextReplacement{Error: err}
+	}
+	mode := fs.FileMode(0644)
+	if info, err := f.filesystem().Stat(f.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	linter := LinterFor(f.Path)
+	var before []Diagnostic
+	if linter != nil {
+		before = runLinter(linter, f.filesystem(), f.Path, lintText(olderFileText))
+	}
+	writeResponse := apply()
 	if writeResponse.Error != nil {
-		_ = os.WriteFile(f.Path, olderFileText, 0644)
+		// Edits fail before writing anything, so there is nothing to revert;
+		// restoring the old content here would clobber a concurrent writer
+		// after a stale-content rejection.
+		return writeResponse
+	}
+	if linter == nil {
 		return writeResponse
 	}
+	updatedFileText, err := f.filesystem().ReadFile(f.Path)
+	if err != nil {
+		return TextReplacement{Error: err}
+	}
+	introduced := introducedDiagnostics(before, runLinter(linter, f.filesystem(), f.Path, lintText(updatedFileText)))
+	if len(introduced) > 0 {
+		if err := f.writeRaw(olderFileText, mode); err != nil {
+			return TextReplacement{Error: fmt.Errorf("could not revert edit after lint errors: %v", err)}
+		}
+		return TextReplacement{
+			Error:       &LintError{Path: f.Path, Diagnostics: introduced},
+			Diagnostics: introduced,
+		}
+	}
 	return writeResponse
 }
 
+// lintText decodes file data for the linters, which expect UTF-8.
+func lintText(raw []byte) []byte {
+	if content, _, err := decodeText(raw); err == nil {
+		return []byte(content)
+	}
+	return raw
+}
+
+// Encoding returns the character encoding detected for the file.
+func (f *File) Encoding() string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	_, format, err := f.readText()
+	if err != nil {
+		return ""
+	}
+	return format.encoding
+}
+
 func isFile(path string) bool {
 	info, err := os.Stat(path)
-	if os.IsNotExist(err) {
+	if err != nil {
 		return false
 	}
 	return !info.IsDir()
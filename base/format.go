@@ -0,0 +1,110 @@
+package base
+
+import (
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Formatter rewrites the full content of a file into its canonical form.
+type Formatter interface {
+	Format(path string, src []byte) ([]byte, error)
+}
+
+type FormatterFunc func(path string, src []byte) ([]byte, error)
+
+func (fn FormatterFunc) Format(path string, src []byte) ([]byte, error) {
+	return fn(path, src)
+}
+
+var (
+	formattersMu sync.RWMutex
+	formatters   = map[string]Formatter{
+		".go": FormatterFunc(func(path string, src []byte) ([]byte, error) {
+			return format.Source(src)
+		}),
+	}
+)
+
+// RegisterFormatter sets the formatter for files with the extension ext,
+// such as ".js". A nil formatter disables formatting for the extension.
+func RegisterFormatter(ext string, formatter Formatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	if formatter == nil {
+		delete(formatters, strings.ToLower(ext))
+		return
+	}
+	formatters[strings.ToLower(ext)] = formatter
+}
+
+// FormatterFor returns the formatter registered for the extension of path,
+// or nil.
+func FormatterFor(path string) Formatter {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	return formatters[strings.ToLower(filepath.Ext(path))]
+}
+
+// CommandFormatter formats through an external command run with
+// FileManager.ExecuteCommand. The content is written to a temporary file with
+// the same extension, whose quoted path replaces "{file}" in Command (or is
+// appended when there is no placeholder); the command must rewrite that file
+// in place, like "gofmt -w" or "prettier --write" do.
+type CommandFormatter struct {
+	Manager *FileManager
+	Command string
+}
+
+func NewCommandFormatter(manager *FileManager, command string) *CommandFormatter {
+	return &CommandFormatter{Manager: manager, Command: command}
+}
+
+func (c *CommandFormatter) Format(path string, src []byte) ([]byte, error) {
+	tmp, err := os.CreateTemp("", "format-*"+filepath.Ext(path))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(src); err != nil {
+		_ = tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	quoted := "'" + strings.ReplaceAll(tmp.Name(), "'", `'\''`) + "'"
+	command := c.Command + " " + quoted
+	if strings.Contains(c.Command, "{file}") {
+		command = strings.ReplaceAll(c.Command, "{file}", quoted)
+	}
+	if _, err := c.Manager.ExecuteCommand(command); err != nil {
+		return nil, fmt.Errorf("formatter %q failed: %v", c.Command, err)
+	}
+	return os.ReadFile(tmp.Name())
+}
+
+// mapLineRange maps the half-open, 0-based line range [start, end) of the old
+// side of ops onto the new side, which has newLen lines.
+func mapLineRange(ops []diffOp, newLen int, start int, end int) (int, int) {
+	newStart := -1
+	for _, op := range ops {
+		if op.kind == '+' {
+			continue
+		}
+		if op.oldLine >= start && newStart < 0 {
+			newStart = op.newLine
+		}
+		if op.oldLine >= end {
+			return newStart, op.newLine
+		}
+	}
+	if newStart < 0 {
+		newStart = newLen
+	}
+	return newStart, newLen
+}
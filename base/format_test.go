@@ -0,0 +1,54 @@
+package base
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAutoFormatGo(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.go")
+	writeFile(t, path, "package a\n\nfunc f() {\n\treturn\n}\n")
+	file, err := NewFileManager(dir, WithAutoFormat(true)).Open("a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := file.Edit("      x:=1\n  _ = x", 4, 4, ScopeFile)
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	want := "package a\n\nfunc f() {\n\tx := 1\n\t_ = x\n}\n"
+	if got := onDisk(t, path); got != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+	if !result.Formatted {
+		t.Error("Formatted = false for an edit gofmt rewrote")
+	}
+	if result.FinalText != "\tx := 1\n\t_ = x" {
+		t.Errorf("FinalText = %q, want the formatted lines", result.FinalText)
+	}
+}
+
+func TestFailingFormatterKeepsEdit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.failfmt")
+	writeFile(t, path, "one\ntwo\n")
+	fm := NewFileManager(dir, WithAutoFormat(true))
+	RegisterFormatter(".failfmt", NewCommandFormatter(fm, "echo broken >&2; exit 1"))
+	t.Cleanup(func() { RegisterFormatter(".failfmt", nil) })
+	file, err := fm.Open("a.failfmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := file.Edit("  TWO  ", 2, 2, ScopeFile)
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	if got := onDisk(t, path); got != "one\n  TWO  \n" {
+		t.Errorf("file = %q, want the unformatted edit", got)
+	}
+	if result.Formatted || !strings.Contains(result.FinalText, "  TWO  ") {
+		t.Errorf("Formatted = %v, FinalText = %q; want the edit as written", result.Formatted, result.FinalText)
+	}
+}
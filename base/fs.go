@@ -0,0 +1,467 @@
+package base
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FS is the writable filesystem FileManager and File perform all I/O through.
+// It builds on the io/fs interfaces, but names are absolute, cleaned paths in
+// the host's syntax instead of the unrooted names io/fs normally uses.
+type FS interface {
+	fs.StatFS
+	fs.ReadFileFS
+	fs.ReadDirFS
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+	Rename(oldname string, newname string) error
+	Chmod(name string, mode fs.FileMode) error
+}
+
+// OSFS is the default FS backed by the operating system.
+type OSFS struct{}
+
+func (OSFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (OSFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (OSFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return writeFileAtomic(name, data, perm)
+}
+
+func (OSFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+func (OSFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (OSFS) Rename(oldname string, newname string) error {
+	return os.Rename(oldname, newname)
+}
+
+func (OSFS) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+// writeFileAtomic writes data to a temporary file next to name, syncs it and
+// renames it over name, so readers see either the old or the new content and
+// a crash never leaves a truncated file. Like os.WriteFile, perm only applies
+// to new files; existing files keep their mode and, where the process may
+// change it, their owner. Symlinks are written through to their target.
+//
+// Two kinds of existing file are written in place instead, without that
+// guarantee: files with other hard links, which a rename would split from
+// them, and files in a directory where no temporary file can be created.
+func writeFileAtomic(name string, data []byte, perm fs.FileMode) error {
+	if target, err := filepath.EvalSymlinks(name); err == nil {
+		name = target
+	}
+	info, err := os.Stat(name)
+	if err == nil {
+		perm = info.Mode().Perm()
+		if links, ok := fileLinks(info); ok && links > 1 {
+			return writeFileInPlace(name, data)
+		}
+	}
+	dir := filepath.Dir(name)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(name)+".tmp-*")
+	if errors.Is(err, fs.ErrPermission) && info != nil {
+		return writeFileInPlace(name, data)
+	}
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if info != nil {
+		// Only a privileged process can give a file to another user, so a
+		// failure leaves the new file owned by the process.
+		if uid, gid, ok := fileOwner(info); ok {
+			_ = tmp.Chown(uid, gid)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return err
+	}
+	committed = true
+	// Sync the directory so the rename itself survives a crash; not every
+	// platform supports it, so failures are ignored.
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+	return nil
+}
+
+// writeFileInPlace truncates the existing file name and writes data to it.
+func writeFileInPlace(name string, data []byte) error {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadOnlyFS wraps an FS and rejects every write with fs.ErrPermission.
+type ReadOnlyFS struct {
+	FS
+}
+
+func NewReadOnlyFS(fsys FS) *ReadOnlyFS {
+	return &ReadOnlyFS{FS: fsys}
+}
+
+func readOnly(op string, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+}
+
+func (r *ReadOnlyFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return readOnly("write", name)
+}
+
+func (r *ReadOnlyFS) MkdirAll(name string, perm fs.FileMode) error {
+	return readOnly("mkdir", name)
+}
+
+func (r *ReadOnlyFS) Remove(name string) error {
+	return readOnly("remove", name)
+}
+
+func (r *ReadOnlyFS) Rename(oldname string, newname string) error {
+	return readOnly("rename", oldname)
+}
+
+func (r *ReadOnlyFS) Chmod(name string, mode fs.FileMode) error {
+	return readOnly("chmod", name)
+}
+
+// MemFS is an in-memory FS. The root directory always exists; other
+// directories are created with MkdirAll.
+type MemFS struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	name    string
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func NewMemFS() *MemFS {
+	root := string(filepath.Separator)
+	return &MemFS{
+		nodes: map[string]*memNode{
+			root: {name: root, mode: fs.ModeDir | 0755, modTime: time.Now()},
+		},
+	}
+}
+
+func (m *MemFS) lookup(op string, name string) (*memNode, error) {
+	node, ok := m.nodes[filepath.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return node, nil
+}
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	node, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if node.mode.IsDir() {
+		entries, _ := m.readDir(filepath.Clean(name))
+		return &memDir{info: node.info(), entries: entries}, nil
+	}
+	return &memFile{Reader: bytes.NewReader(slices.Clone(node.data)), info: node.info()}, nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	node, err := m.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return node.info(), nil
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	node, err := m.lookup("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return slices.Clone(node.data), nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	node, err := m.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return m.readDir(filepath.Clean(name))
+}
+
+func (m *MemFS) readDir(dir string) ([]fs.DirEntry, error) {
+	entries := make([]fs.DirEntry, 0)
+	for path, node := range m.nodes {
+		if path != dir && filepath.Dir(path) == dir {
+			entries = append(entries, fs.FileInfoToDirEntry(node.info()))
+		}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if parent, ok := m.nodes[filepath.Dir(name)]; !ok || !parent.mode.IsDir() {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrNotExist}
+	}
+	if node, ok := m.nodes[name]; ok {
+		if node.mode.IsDir() {
+			return &fs.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+		}
+		node.data = slices.Clone(data)
+		node.modTime = time.Now()
+		return nil
+	}
+	m.nodes[name] = &memNode{name: filepath.Base(name), data: slices.Clone(data), mode: perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	for dir := name; ; dir = filepath.Dir(dir) {
+		if node, ok := m.nodes[dir]; ok {
+			if !node.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+			}
+			break
+		}
+		m.nodes[dir] = &memNode{name: filepath.Base(dir), mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	}
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	node, err := m.lookup("remove", name)
+	if err != nil {
+		return err
+	}
+	if node.mode.IsDir() {
+		if entries, _ := m.readDir(name); len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+		}
+	}
+	delete(m.nodes, name)
+	return nil
+}
+
+func (m *MemFS) Rename(oldname string, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldname, newname = filepath.Clean(oldname), filepath.Clean(newname)
+	node, err := m.lookup("rename", oldname)
+	if err != nil {
+		return err
+	}
+	if parent, ok := m.nodes[filepath.Dir(newname)]; !ok || !parent.mode.IsDir() {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrNotExist}
+	}
+	prefix := oldname + string(filepath.Separator)
+	moved := make(map[string]*memNode)
+	for path, child := range m.nodes {
+		if strings.HasPrefix(path, prefix) {
+			moved[newname+string(filepath.Separator)+strings.TrimPrefix(path, prefix)] = child
+			delete(m.nodes, path)
+		}
+	}
+	for path, child := range moved {
+		m.nodes[path] = child
+	}
+	delete(m.nodes, oldname)
+	node.name = filepath.Base(newname)
+	m.nodes[newname] = node
+	return nil
+}
+
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, err := m.lookup("chmod", name)
+	if err != nil {
+		return err
+	}
+	node.mode = node.mode.Type() | mode.Perm()
+	return nil
+}
+
+func (n *memNode) info() fs.FileInfo {
+	return &memInfo{name: n.name, size: int64(len(n.data)), mode: n.mode, modTime: n.modTime}
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i *memInfo) Name() string       { return i.name }
+func (i *memInfo) Size() int64        { return i.size }
+func (i *memInfo) Mode() fs.FileMode  { return i.mode }
+func (i *memInfo) ModTime() time.Time { return i.modTime }
+func (i *memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memInfo) Sys() any           { return nil }
+
+// memFile is an open regular file of a MemFS. It supports io.Seeker and
+// io.ReaderAt like *os.File does.
+type memFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+type memDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func isFileFS(fsys FS, path string) bool {
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return false
+	}
+	return !info.IsDir()
+}
+
+func isDirFS(fsys FS, path string) bool {
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return false
+	}
+	return info.IsDir()
+}
+
+// walkFS calls fn for root and every file and directory below it, in lexical
+// order. Unlike fs.WalkDir it joins names with the host separator.
+func walkFS(fsys FS, root string, fn func(path string, entry fs.DirEntry) error) error {
+	info, err := fsys.Stat(root)
+	if err != nil {
+		return err
+	}
+	var walk func(path string, entry fs.DirEntry) error
+	walk = func(path string, entry fs.DirEntry) error {
+		if err := fn(path, entry); err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		entries, err := fsys.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, child := range entries {
+			if err := walk(filepath.Join(path, child.Name()), child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(root, fs.FileInfoToDirEntry(info))
+}
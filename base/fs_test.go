@@ -0,0 +1,159 @@
+package base
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemFS(t *testing.T) {
+	m := NewMemFS()
+	dir := filepath.Join(string(filepath.Separator), "work", "sub")
+	name := filepath.Join(dir, "a.txt")
+	if err := m.WriteFile(name, []byte("a\n"), 0644); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("WriteFile without its directory: %v, want ErrNotExist", err)
+	}
+	if err := m.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile(name, []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile(filepath.Join(dir, "b.txt"), []byte("bb\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := m.ReadFile(name); err != nil || string(data) != "a\n" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	file, err := m.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := io.ReadAll(file); err != nil || string(data) != "a\n" {
+		t.Errorf("Open and read = %q, %v", data, err)
+	}
+	info, err := m.Stat(filepath.Join(dir, "b.txt"))
+	if err != nil || info.Size() != 3 || info.Mode() != 0600 || info.IsDir() || info.Name() != "b.txt" {
+		t.Errorf("Stat = %v, %v", info, err)
+	}
+	if info, err := m.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("Stat of a directory = %v, %v", info, err)
+	}
+	if _, err := m.Stat(filepath.Join(dir, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat of a missing file: %v, want ErrNotExist", err)
+	}
+
+	entries, err := m.ReadDir(dir)
+	if err != nil || len(entries) != 2 || entries[0].Name() != "a.txt" || entries[1].Name() != "b.txt" {
+		t.Errorf("ReadDir = %v, %v", entries, err)
+	}
+	if entries, err := m.ReadDir(filepath.Dir(dir)); err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		t.Errorf("ReadDir of the parent = %v, %v", entries, err)
+	}
+	if err := m.Remove(dir); err == nil {
+		t.Error("Remove of a directory that is not empty succeeded")
+	}
+	if err := m.Rename(name, filepath.Join(dir, "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ReadFile(name); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile of a renamed file: %v, want ErrNotExist", err)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	writeFile(t, name, "a\n")
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Link(name, link); err != nil {
+		t.Skip("hard links are not supported:", err)
+	}
+	if err := (OSFS{}).WriteFile(name, []byte("b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := onDisk(t, link); got != "b\n" {
+		t.Errorf("hard link after a write = %q, want the new content", got)
+	}
+
+	name = filepath.Join(dir, "owned.txt")
+	writeFile(t, name, "a\n")
+	if err := os.Chown(name, 1234, 5678); err != nil {
+		t.Skip("cannot change the owner of a file:", err)
+	}
+	if err := (OSFS{}).WriteFile(name, []byte("b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid, gid, ok := fileOwner(info); ok && (uid != 1234 || gid != 5678) {
+		t.Errorf("owner after a write = %d:%d, want 1234:5678", uid, gid)
+	}
+}
+
+func TestReadOnlyFS(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	writeFile(t, name, "a\n")
+	r := NewReadOnlyFS(OSFS{})
+	if data, err := r.ReadFile(name); err != nil || string(data) != "a\n" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	for op, err := range map[string]error{
+		"WriteFile": r.WriteFile(name, []byte("b\n"), 0644),
+		"MkdirAll":  r.MkdirAll(filepath.Join(dir, "sub"), 0755),
+		"Remove":    r.Remove(name),
+		"Rename":    r.Rename(name, filepath.Join(dir, "b.txt")),
+		"Chmod":     r.Chmod(name, 0600),
+	} {
+		if !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("%s: %v, want ErrPermission", op, err)
+		}
+	}
+	if data, _ := os.ReadFile(name); string(data) != "a\n" {
+		t.Errorf("file changed to %q", data)
+	}
+
+	file, err := NewFileManager(dir, WithFS(r)).Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := file.Write("b\n"); !errors.Is(result.Error, fs.ErrPermission) {
+		t.Errorf("File.Write on a read-only FS: %v, want ErrPermission", result.Error)
+	}
+}
+
+func TestTree(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "")
+	if err := os.MkdirAll(filepath.Join(dir, "sub", "deep"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "sub", "b.txt"), "")
+	writeFile(t, filepath.Join(dir, "sub", "deep", "c.txt"), "")
+	// Symlinks are listed but not followed, so a link cannot loop.
+	if err := os.Symlink(dir, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	fm := NewFileManager(dir)
+
+	for _, tc := range []struct {
+		depth   int
+		exclude []string
+		want    string
+	}{
+		{-1, nil, "__ a.txt\n__ link\n__ sub\n  |__ b.txt\n  |__ deep\n  |  |__ c.txt\n"},
+		{1, nil, "__ a.txt\n__ link\n__ sub\n  |__ b.txt\n  |__ deep\n"},
+		{-1, []string{filepath.Join(dir, "sub", "deep")}, "__ a.txt\n__ link\n__ sub\n  |__ b.txt\n  |__ deep\n"},
+	} {
+		if got := fm.Tree(tc.depth, tc.exclude); got != tc.want {
+			t.Errorf("Tree(%d, %v) =\n%s\nwant\n%s", tc.depth, tc.exclude, got, tc.want)
+		}
+	}
+}
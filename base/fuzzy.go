@@ -0,0 +1,308 @@
+package base
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// MatchStrategy tells how Replace located the text it replaced.
+type MatchStrategy string
+
+const (
+	MatchExact MatchStrategy = "exact"
+	MatchRegex MatchStrategy = "regex"
+	// MatchIndentation matched lines that differ only in their common
+	// indentation and trailing whitespace.
+	MatchIndentation MatchStrategy = "indentation"
+	// MatchWhitespace matched lines that differ only in leading and trailing
+	// whitespace.
+	MatchWhitespace MatchStrategy = "whitespace"
+	// MatchSimilar matched the most similar block of lines by edit distance.
+	MatchSimilar MatchStrategy = "similar"
+)
+
+const (
+	// fuzzyMinSimilarity is the lowest similarity MatchSimilar accepts.
+	fuzzyMinSimilarity = 0.8
+	// fuzzyMaxRunes bounds the size of a search that is compared by edit
+	// distance against every block of the file.
+	fuzzyMaxRunes = 4096
+)
+
+type fuzzyMatch struct {
+	strategy   MatchStrategy
+	similarity float64
+}
+
+// fuzzyMatches looks for blocks of whole lines of content that match search
+// with each strategy in turn, from the strictest, and returns the matches of
+// the first that finds any. Matches span from the start of the first line to
+// the end of the last, without its newline.
+func fuzzyMatches(content string, search string) (fuzzyMatch, [][]int) {
+	wanted := strings.Split(strings.Trim(search, "\n"), "\n")
+	lines := strings.Split(content, "\n")
+	starts := make([]int, len(lines))
+	for i, offset := 1, 0; i < len(lines); i++ {
+		offset += len(lines[i-1]) + 1
+		starts[i] = offset
+	}
+	match := fuzzyMatch{}
+	if strings.TrimSpace(search) == "" {
+		return match, nil
+	}
+	block := func(i int, count int) []int {
+		last := i + count - 1
+		return []int{starts[i], starts[last] + len(lines[last])}
+	}
+
+	strategies := []struct {
+		strategy  MatchStrategy
+		normalize func([]string) []string
+	}{
+		{MatchIndentation, dedent},
+		{MatchWhitespace, trimLines},
+	}
+	for _, s := range strategies {
+		target := strings.Join(s.normalize(wanted), "\n")
+		var matches [][]int
+		for i := 0; i+len(wanted) <= len(lines); i++ {
+			if strings.Join(s.normalize(lines[i:i+len(wanted)]), "\n") == target {
+				matches = append(matches, block(i, len(wanted)))
+				i += len(wanted) - 1
+			}
+		}
+		if len(matches) > 0 {
+			match.strategy, match.similarity = s.strategy, 1
+			return match, matches
+		}
+	}
+
+	target := []rune(strings.Join(trimLines(wanted), "\n"))
+	if len(target) > fuzzyMaxRunes {
+		return match, nil
+	}
+	// Blocks have the line count of the search, or one line more or less.
+	// The lines are trimmed once. The runes of the blocks are counted by
+	// sliding a window over the lines, which rules most blocks out before
+	// their edit distance is computed, and the blocks starting on the same
+	// line share the rows of that computation.
+	trimmed := make([][]rune, len(lines))
+	for i, line := range lines {
+		trimmed[i] = []rune(strings.TrimSpace(line))
+	}
+	bag := runeBag{counts: make(map[rune]int)}
+	bag.add(target, -1)
+	base := len(wanted) - 1
+	for _, line := range trimmed[:min(base, len(trimmed))] {
+		bag.add(line, 1)
+	}
+	var matches [][]int
+	best, bestEnd := fuzzyMinSimilarity, -1
+	for i := 0; i+max(base, 1) <= len(lines); i++ {
+		var counts, longest, bounds []int
+		widest := 0
+		for count := max(base, 1); count <= base+2 && i+count <= len(lines); count++ {
+			if count > base {
+				bag.add(trimmed[i+count-1], 1)
+			}
+			// The bag holds the target as negative counts and a newline
+			// after every line.
+			size := max(bag.size+len(target), len(target))
+			bound := int(math.Ceil(float64(size) * (1 - best)))
+			if bag.distance() <= bound {
+				counts = append(counts, count)
+				longest = append(longest, size)
+				bounds = append(bounds, bound)
+				widest = max(widest, bound)
+			}
+		}
+		for count := base + 1; count <= base+2 && i+count <= len(lines); count++ {
+			bag.add(trimmed[i+count-1], -1)
+		}
+		if base > 0 {
+			bag.add(trimmed[i], -1)
+			if i+base < len(lines) {
+				bag.add(trimmed[i+base], 1)
+			}
+		}
+		if len(counts) == 0 {
+			continue
+		}
+		distances := prefixDistances(trimmed[i:i+counts[len(counts)-1]], counts, target, widest)
+		similarity, count := 0.0, 0
+		for k, distance := range distances {
+			if distance < 0 || distance > bounds[k] {
+				continue
+			}
+			// The block with the line count of the search wins a tie.
+			current := 1 - float64(distance)/float64(longest[k])
+			if current > similarity || current == similarity && counts[k] == len(wanted) {
+				similarity, count = current, counts[k]
+			}
+		}
+		switch {
+		case count == 0:
+		case similarity > best:
+			best, matches = similarity, [][]int{block(i, count)}
+			bestEnd = i + count
+		case similarity == best && i >= bestEnd:
+			// Equally good blocks that do not overlap make the match ambiguous.
+			matches = append(matches, block(i, count))
+			bestEnd = i + count
+		}
+	}
+	if len(matches) > 0 {
+		match.strategy, match.similarity = MatchSimilar, best
+	}
+	return match, matches
+}
+
+// runeBag counts how many more times each rune occurs in a block of lines
+// than in the search. An edit removes at most one surplus rune and adds at
+// most one missing rune, so the larger of the two totals is a lower bound of
+// the edit distance.
+type runeBag struct {
+	counts  map[rune]int
+	surplus int
+	missing int
+	// size is the total of the counts.
+	size int
+}
+
+// add counts the runes of line and a newline delta times.
+func (b *runeBag) add(line []rune, delta int) {
+	for _, r := range line {
+		b.count(r, delta)
+	}
+	b.count('\n', delta)
+}
+
+func (b *runeBag) count(r rune, delta int) {
+	before := b.counts[r]
+	after := before + delta
+	b.counts[r] = after
+	b.surplus += max(after, 0) - max(before, 0)
+	b.missing += max(-after, 0) - max(-before, 0)
+	b.size += delta
+}
+
+func (b *runeBag) distance() int {
+	return max(b.surplus, b.missing)
+}
+
+// prefixDistances returns the Levenshtein distances between target and the
+// first count lines joined by newlines, for each of counts in increasing
+// order. A distance larger than bound is returned as -1. Only the diagonal
+// band of width bound is computed, and each row once, so the distance of a
+// longer prefix costs only its extra lines.
+func prefixDistances(lines [][]rune, counts []int, target []rune, bound int) []int {
+	distances := make([]int, len(counts))
+	for k := range distances {
+		distances[k] = -1
+	}
+	const far = 1 << 30
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for j := range previous {
+		previous[j] = j
+		if j > bound {
+			previous[j] = far
+		}
+	}
+	i, next := 0, 0
+	row := func(r rune) bool {
+		i++
+		low, high := max(1, i-bound), min(len(target), i+bound)
+		current[0] = far
+		if i <= bound {
+			current[0] = i
+		}
+		if low > 1 {
+			current[low-1] = far
+		}
+		rowMin := current[0]
+		for j := low; j <= high; j++ {
+			cost := 1
+			if r == target[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j-1]+cost, previous[j]+1, current[j-1]+1)
+			rowMin = min(rowMin, current[j])
+		}
+		if high < len(target) {
+			current[high+1] = far
+		}
+		previous, current = current, previous
+		return rowMin <= bound
+	}
+	for l, line := range lines {
+		if l > 0 && !row('\n') {
+			return distances
+		}
+		for _, r := range line {
+			if !row(r) {
+				return distances
+			}
+		}
+		if next < len(counts) && counts[next] == l+1 {
+			// The last column lies outside the band while the prefix is
+			// shorter than the target by more than bound.
+			if len(target) <= i+bound && previous[len(target)] <= bound {
+				distances[next] = previous[len(target)]
+			}
+			next++
+		}
+	}
+	return distances
+}
+
+func trimLines(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimSpace(line)
+	}
+	return trimmed
+}
+
+// dedent removes trailing whitespace and the indentation common to all
+// non-blank lines, keeping the indentation of the lines relative to each other.
+func dedent(lines []string) []string {
+	indent := blockIndent(strings.Join(lines, "\n"))
+	dedented := make([]string, len(lines))
+	for i, line := range lines {
+		dedented[i] = strings.TrimRight(strings.TrimPrefix(line, indent), " \t\r")
+	}
+	return dedented
+}
+
+// blockIndent returns the shortest indentation of the non-blank lines of text.
+func blockIndent(text string) string {
+	indent, found := "", false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		current := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if !found || utf8.RuneCountInString(current) < utf8.RuneCountInString(indent) {
+			indent, found = current, true
+		}
+	}
+	return indent
+}
+
+// reindent replaces the common indentation of text with to, keeping the
+// relative indentation of its lines. Text is treated as whole lines, so a
+// trailing newline is dropped.
+func reindent(text string, to string) string {
+	from := blockIndent(text)
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
+			continue
+		}
+		lines[i] = to + strings.TrimPrefix(line, from)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,66 @@
+package base
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplaceSimilarBlocks(t *testing.T) {
+	const content = "func run() {\n" +
+		"\talpha := compute(first)\n" +
+		"\tbeta := compute(second)\n" +
+		"\tx++\n" +
+		"\tgamma := compute(third)\n" +
+		"}\n"
+	tests := []struct {
+		name    string
+		search  string
+		matched string
+	}{
+		{
+			name:    "same line count",
+			search:  "alpha := compute(frist)\nbeta := compute(second)\nx++\ngamma := compute(third)",
+			matched: "\talpha := compute(first)\n\tbeta := compute(second)\n\tx++\n\tgamma := compute(third)",
+		},
+		{
+			name:    "line missing from search",
+			search:  "alpha := compute(first)\nbeta := compute(second)\ngamma := compute(third)",
+			matched: "\talpha := compute(first)\n\tbeta := compute(second)\n\tx++\n\tgamma := compute(third)",
+		},
+		{
+			name:    "line added to search",
+			search:  "beta := compute(second)\nx++\ny++\ngamma := compute(third)",
+			matched: "\tbeta := compute(second)\n\tx++\n\tgamma := compute(third)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "a.go")
+			writeFile(t, path, content)
+			file, err := NewFileManager(dir).Open("a.go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := file.Replace(test.search, "done()", WithFuzzy(true))
+			if result.Error != nil {
+				t.Fatal(result.Error)
+			}
+			if result.Strategy != MatchSimilar {
+				t.Errorf("strategy %q, want %q", result.Strategy, MatchSimilar)
+			}
+			if result.ReplacedText != test.matched {
+				t.Errorf("replaced %q, want %q", result.ReplacedText, test.matched)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.Replace(content, test.matched, "\tdone()", 1); string(data) != want {
+				t.Errorf("file:\n%s\nwant:\n%s", data, want)
+			}
+		})
+	}
+}
@@ -36,3 +36,10 @@ func generateID() string {
 	}
 	return _id
 }
+
+// 登记已存在的ID（例如从会话恢复时）
+func registerID(id string) {
+	mu.Lock()
+	defer mu.Unlock()
+	shellIDs[id] = struct{}{}
+}
@@ -0,0 +1,202 @@
+package base
+
+import (
+	"bytes"
+	"strings"
+	"time"
+)
+
+// racyInterval is how close to the time an index was built a file's
+// modification time must be for the index to be checked by content hash as
+// well: filesystems with coarse timestamps cannot tell apart writes made
+// within the same tick, so size and mtime alone could miss them.
+const racyInterval = 2 * time.Second
+
+// fineRacyInterval replaces racyInterval for modification times with
+// sub-millisecond precision. Such filesystems still only advance them once
+// per kernel clock tick, which is at most 10ms.
+const fineRacyInterval = 20 * time.Millisecond
+
+// racyWindow returns the racy interval for a file modified at modTime.
+func racyWindow(modTime time.Time) time.Duration {
+	if modTime.Nanosecond()%int(time.Millisecond) != 0 {
+		return fineRacyInterval
+	}
+	return racyInterval
+}
+
+// lineIndex holds the byte offset where each line of a file starts, so
+// windows are read directly from the file instead of rescanning it. It keeps
+// no content.
+type lineIndex struct {
+	format  textFormat
+	starts  []int
+	builtAt time.Time
+}
+
+// newLineIndex indexes raw, the data of a file in format.
+func newLineIndex(raw []byte, format textFormat) *lineIndex {
+	starts := format.scanStarts(nil, raw, len(format.bom), len(raw))
+	return &lineIndex{format: format, starts: starts, builtAt: time.Now()}
+}
+
+// edited returns the index of data, the file written in format after its
+// content went from original to content. Only the lines between the common
+// prefix and suffix of the two are scanned; the offsets of the lines after
+// them are shifted by the change in size.
+func (ix *lineIndex) edited(original string, content string, data []byte, format textFormat) *lineIndex {
+	prefix := 0
+	for prefix < min(len(original), len(content)) && original[prefix] == content[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < min(len(original), len(content))-prefix && original[len(original)-1-suffix] == content[len(content)-1-suffix] {
+		suffix++
+	}
+	// Lines whose newline is in the prefix are unchanged, and so are the
+	// lines that start in the suffix.
+	before := strings.Count(original[:prefix], "\n")
+	after := strings.Count(original[max(len(original)-suffix-1, 0):max(len(original)-1, 0)], "\n")
+	if suffix == len(original) && original != "" {
+		after++
+	}
+
+	size := int(ix.format.fingerprint.Size)
+	delta := len(data) - size
+	from, to := size, len(data)
+	if before < len(ix.starts) {
+		from = ix.starts[before]
+	}
+	tail := ix.starts[len(ix.starts)-after:]
+	if len(tail) > 0 {
+		to = tail[0] + delta
+	}
+	starts := make([]int, 0, len(ix.starts)+strings.Count(content, "\n")-strings.Count(original, "\n")+1)
+	starts = append(starts, ix.starts[:before]...)
+	starts = format.scanStarts(starts, data, from, to)
+	for _, start := range tail {
+		starts = append(starts, start+delta)
+	}
+	return &lineIndex{format: format, starts: starts, builtAt: time.Now()}
+}
+
+// newline returns "\n" in the encoding of t.
+func (t textFormat) newline() []byte {
+	switch t.encoding {
+	case EncodingUTF16LE:
+		return []byte{'\n', 0}
+	case EncodingUTF16BE:
+		return []byte{0, '\n'}
+	}
+	return []byte{'\n'}
+}
+
+// scanStarts appends to starts the offset of every line of raw that starts
+// in [from, to), where from is the start of a line.
+func (t textFormat) scanStarts(starts []int, raw []byte, from int, to int) []int {
+	newline := t.newline()
+	for from < to {
+		starts = append(starts, from)
+		i := indexAligned(raw[from:to], newline)
+		if i < 0 {
+			break
+		}
+		from += i + len(newline)
+	}
+	return starts
+}
+
+// indexAligned is bytes.Index for sep found at a multiple of its length, so
+// that a UTF-16 newline is not matched across two characters.
+func indexAligned(data []byte, sep []byte) int {
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], sep)
+		if i < 0 {
+			return -1
+		}
+		if (offset+i)%len(sep) == 0 {
+			return offset + i
+		}
+		offset += i + 1
+	}
+}
+
+// lineCount returns the number of lines, counting a last line without a
+// newline.
+func (ix *lineIndex) lineCount() int {
+	return len(ix.starts)
+}
+
+// readLines returns lines [start, end) of the file, clamped to it and
+// without their line endings. Only the bytes of those lines are read.
+func (f *File) readLines(ix *lineIndex, start int, end int) ([]string, error) {
+	start, end = max(start, 0), min(end, ix.lineCount())
+	if start >= end {
+		return nil, nil
+	}
+	to := int(ix.format.fingerprint.Size)
+	if end < len(ix.starts) {
+		to = ix.starts[end]
+	}
+	data, err := f.readAt(int64(ix.starts[start]), to-ix.starts[start])
+	if err != nil {
+		return nil, err
+	}
+	text, err := ix.format.decode(data)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines, nil
+}
+
+// loadIndex returns the index of the file, rebuilding it when the file's
+// size or modification time changed since it was built, or its hash changed
+// while the modification time is too recent to be trusted.
+func (f *File) loadIndex() (*lineIndex, error) {
+	info, err := f.filesystem().Stat(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	cached := f.index.Load()
+	if cached != nil && cached.format.fingerprint.Size == info.Size() && cached.format.fingerprint.ModTime.Equal(info.ModTime()) {
+		if cached.builtAt.Sub(info.ModTime()) > racyWindow(info.ModTime()) {
+			return cached, nil
+		}
+	} else {
+		cached = nil
+	}
+
+	raw, err := f.filesystem().ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	hash := contentHash(raw)
+	if cached != nil && cached.format.fingerprint.Hash == hash {
+		// Once the modification time is old enough to be trusted, the
+		// index is marked as built now so later reads skip the hash.
+		if now := time.Now(); now.Sub(info.ModTime()) > racyWindow(info.ModTime()) {
+			refreshed := *cached
+			refreshed.builtAt = now
+			f.index.CompareAndSwap(cached, &refreshed)
+			return &refreshed, nil
+		}
+		return cached, nil
+	}
+	_, format, err := decodeText(raw)
+	if err != nil {
+		return nil, err
+	}
+	format.mode = info.Mode().Perm()
+	format.fingerprint = Fingerprint{Hash: hash, Size: int64(len(raw)), ModTime: info.ModTime()}
+	ix := newLineIndex(raw, format)
+	// A file that changed between Stat and ReadFile would be cached under
+	// the wrong modification time.
+	if int64(len(raw)) == info.Size() {
+		f.index.Store(ix)
+	}
+	return ix, nil
+}
@@ -0,0 +1,228 @@
+package base
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingFS counts the ReadFile calls made through it.
+type countingFS struct {
+	FS
+	reads atomic.Int64
+}
+
+func (c *countingFS) ReadFile(name string) ([]byte, error) {
+	c.reads.Add(1)
+	return c.FS.ReadFile(name)
+}
+
+func writeLines(t testing.TB, path string, n int) {
+	t.Helper()
+	var text strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&text, "line %d of a generated file\n", i)
+	}
+	if err := os.WriteFile(path, []byte(text.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexTrustsSettledModTime(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	writeLines(t, path, 10)
+	fsys := &countingFS{FS: OSFS{}}
+	fm := NewFileManager(dir, WithFS(fsys))
+	file, err := fm.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := file.Write("one\ntwo\n"); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	// Our own write leaves an index built right at the modification time.
+	// Age both, as if the views came a while after the write: the first
+	// view re-hashes the file, later ones must trust it again.
+	old := time.Now().Add(-2 * racyInterval)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aged := *file.index.Load()
+	aged.format.fingerprint.ModTime, aged.builtAt = info.ModTime(), info.ModTime()
+	file.index.Store(&aged)
+	reads := fsys.reads.Load()
+	for i := 0; i < 5; i++ {
+		if _, err := file.View(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := fsys.reads.Load() - reads; got != 1 {
+		t.Errorf("5 views read the file %d times, want 1", got)
+	}
+}
+
+func TestIndexSeesExternalChange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	writeLines(t, path, 10)
+	file, err := NewFileManager(dir).Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total := file.TotalLines(); total != 10 {
+		t.Fatalf("TotalLines() = %d, want 10", total)
+	}
+	writeLines(t, path, 20)
+	if total := file.TotalLines(); total != 20 {
+		t.Errorf("TotalLines() after external write = %d, want 20", total)
+	}
+}
+
+func TestIndexFollowsEdits(t *testing.T) {
+	utf16le := []byte{0xFF, 0xFE}
+	for _, r := range "a\nb\nc\n" {
+		utf16le = append(utf16le, byte(r), 0)
+	}
+	utf16be := []byte{0xFE, 0xFF}
+	for _, r := range "a\nb\nc" {
+		utf16be = append(utf16be, 0, byte(r))
+	}
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{"lf", []byte("a\nb\nc\n")},
+		{"no final newline", []byte("a\nb\nc")},
+		{"crlf", []byte("a\r\nb\r\nc\r\n")},
+		{"bom", []byte("\xEF\xBB\xBFa\nb\nc\n")},
+		{"latin-1", []byte("a\nb\xe9\nc\n")},
+		{"utf-16le", utf16le},
+		{"utf-16be", utf16be},
+	}
+	edits := []func(*File) TextReplacement{
+		func(f *File) TextReplacement { return f.Edit("B\nB2", 2, 2, ScopeFile) },
+		func(f *File) TextReplacement { return f.Edit("start", 1, 1, ScopeFile) },
+		func(f *File) TextReplacement { return f.Edit("end\nmore", 4, 4, ScopeFile) },
+		func(f *File) TextReplacement { return f.Edit("", 2, 3, ScopeFile) },
+		func(f *File) TextReplacement { return f.Replace("start", "x") },
+		func(f *File) TextReplacement { return f.Write("one\ntwo\n") },
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "a.txt")
+		if err := os.WriteFile(path, test.raw, 0644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := NewFileManager(dir).Open("a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.View(); err != nil {
+			t.Fatal(err)
+		}
+		for i, edit := range edits {
+			if result := edit(file); result.Error != nil {
+				t.Fatalf("%s: edit %d: %v", test.name, i, result.Error)
+			}
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ix := file.index.Load()
+			if want := newLineIndex(raw, ix.format).starts; !slices.Equal(ix.starts, want) {
+				t.Errorf("%s: edit %d: offsets %v, want %v", test.name, i, ix.starts, want)
+			}
+			content, _, err := decodeText(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := splitLines(content)
+			got, err := file.readLines(ix, 0, ix.lineCount())
+			if err != nil || !slices.Equal(got, want) {
+				t.Errorf("%s: edit %d: lines %q, %v; want %q", test.name, i, got, err, want)
+			}
+		}
+	}
+}
+
+func TestIndexAfterEdit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	writeLines(t, path, 1000)
+	fsys := &countingFS{FS: OSFS{}}
+	file, err := NewFileManager(dir, WithFS(fsys)).Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := file.Edit("edited", 500, 500, ScopeFile); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if racyWindow(info.ModTime()) != fineRacyInterval {
+		t.Skip("the filesystem has coarse modification times")
+	}
+	// Past the racy interval the first view checks the hash once; scrolling
+	// after that reads only the window.
+	time.Sleep(2 * fineRacyInterval)
+	reads := fsys.reads.Load()
+	file.Goto(495)
+	for i := 0; i < 10; i++ {
+		view, err := file.View()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && view.Lines[500] != "edited" {
+			t.Errorf("line 500 after the edit = %q", view.Lines[500])
+		}
+		file.Scroll(1, ScrollDown)
+	}
+	if got := fsys.reads.Load() - reads; got > 1 {
+		t.Errorf("10 views after an edit read the whole file %d times, want at most 1", got)
+	}
+}
+
+// BenchmarkScroll views a window near the end of files of growing size. With
+// the index the time per view stays flat instead of growing with the file.
+func BenchmarkScroll(b *testing.B) {
+	for _, n := range []int{1_000, 20_000, 200_000} {
+		b.Run(fmt.Sprintf("lines=%d", n), func(b *testing.B) {
+			dir := b.TempDir()
+			writeLines(b, filepath.Join(dir, "gen.txt"), n)
+			old := time.Now().Add(-2 * racyInterval)
+			if err := os.Chtimes(filepath.Join(dir, "gen.txt"), old, old); err != nil {
+				b.Fatal(err)
+			}
+			file, err := NewFileManager(dir).Open("gen.txt")
+			if err != nil {
+				b.Fatal(err)
+			}
+			file.Goto(n - 200)
+			if _, err := file.View(); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				file.Scroll(1, ScrollDown)
+				if _, err := file.View(); err != nil {
+					b.Fatal(err)
+				}
+				if i%100 == 99 {
+					file.Scroll(100, ScrollUp)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package base
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxLineWidth is the number of characters after which viewed lines
+// are cut, so one minified line cannot flood the output.
+const DefaultMaxLineWidth = 2000
+
+// truncateLine cuts line to width characters and appends a marker with the
+// full length. A width of zero or less disables truncation.
+func truncateLine(line string, width int) string {
+	if width <= 0 || len(line) <= width {
+		return line
+	}
+	length := utf8.RuneCountInString(line)
+	if length <= width {
+		return line
+	}
+	cut := 0
+	for i := 0; i < width; i++ {
+		_, size := utf8.DecodeRuneInString(line[cut:])
+		cut += size
+	}
+	return fmt.Sprintf("%s … [line truncated: showing %d of %d characters]", line[:cut], width, length)
+}
+
+// scanLines calls fn with every line of r, numbered from 1 and without its
+// line ending. Unlike bufio.Scanner it has no limit on the length of a line.
+// Read errors are returned.
+func scanLines(r io.Reader, fn func(lineno int, line string)) error {
+	reader := bufio.NewReader(r)
+	for lineno := 1; ; lineno++ {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(line, "\n")
+			fn(lineno, strings.TrimSuffix(line, "\r"))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// lineWidth returns the width lines of the file are truncated at in views.
+func (f *File) lineWidth() int {
+	if f.MaxLineWidth == 0 {
+		return DefaultMaxLineWidth
+	}
+	return f.MaxLineWidth
+}
+
+// TruncateLine cuts line at the file's MaxLineWidth the way View does, for
+// callers that show text from the file outside a view.
+func (f *File) TruncateLine(line string) string {
+	return truncateLine(line, f.lineWidth())
+}
@@ -0,0 +1,292 @@
+package base
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Diagnostic is a problem reported by a linter. Line and Column are 1-based;
+// Column is 0 when the linter cannot tell.
+type Diagnostic struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Line, d.Column, d.Message)
+}
+
+// LintError is returned when an edit introduces new diagnostics.
+type LintError struct {
+	Path        string
+	Diagnostics []Diagnostic
+}
+
+func (e *LintError) Error() string {
+	messages := make([]string, 0, len(e.Diagnostics))
+	for _, diagnostic := range e.Diagnostics {
+		messages = append(messages, filepath.Base(e.Path)+":"+diagnostic.String())
+	}
+	return "lint errors introduced by the edit:\n" + strings.Join(messages, "\n")
+}
+
+// Linter checks the full content of a file.
+type Linter interface {
+	Lint(path string, content []byte) []Diagnostic
+}
+
+type LinterFunc func(path string, content []byte) []Diagnostic
+
+func (fn LinterFunc) Lint(path string, content []byte) []Diagnostic {
+	return fn(path, content)
+}
+
+// FSLinter is a Linter that also needs the files next to the one it checks,
+// such as the rest of a Go package. LintFS is given the filesystem the file
+// is on.
+type FSLinter interface {
+	Linter
+	LintFS(fsys FS, path string, content []byte) []Diagnostic
+}
+
+// runLinter runs linter on content, passing fsys to linters that want it.
+func runLinter(linter Linter, fsys FS, path string, content []byte) []Diagnostic {
+	if fsLinter, ok := linter.(FSLinter); ok {
+		return fsLinter.LintFS(fsys, path, content)
+	}
+	return linter.Lint(path, content)
+}
+
+var (
+	lintersMu sync.RWMutex
+	linters   = map[string]Linter{
+		".go":   goLinter{},
+		".json": LinterFunc(lintJSON),
+		".yaml": LinterFunc(lintYAML),
+		".yml":  LinterFunc(lintYAML),
+	}
+)
+
+// RegisterLinter sets the linter for files with the extension ext, such as
+// ".py". A nil linter disables linting for the extension.
+func RegisterLinter(ext string, linter Linter) {
+	lintersMu.Lock()
+	defer lintersMu.Unlock()
+	if linter == nil {
+		delete(linters, strings.ToLower(ext))
+		return
+	}
+	linters[strings.ToLower(ext)] = linter
+}
+
+// LinterFor returns the linter registered for the extension of path, or nil.
+func LinterFor(path string) Linter {
+	lintersMu.RLock()
+	defer lintersMu.RUnlock()
+	return linters[strings.ToLower(filepath.Ext(path))]
+}
+
+// introducedDiagnostics returns the diagnostics in after that were not
+// already in before. Diagnostics are matched by message, since an edit
+// shifts the lines of everything below it.
+func introducedDiagnostics(before []Diagnostic, after []Diagnostic) []Diagnostic {
+	existing := make(map[string]int)
+	for _, diagnostic := range before {
+		existing[diagnostic.Message]++
+	}
+	introduced := make([]Diagnostic, 0)
+	for _, diagnostic := range after {
+		if existing[diagnostic.Message] > 0 {
+			existing[diagnostic.Message]--
+			continue
+		}
+		introduced = append(introduced, diagnostic)
+	}
+	return introduced
+}
+
+var (
+	// The stdlib importer caches packages and is not safe for concurrent use.
+	goImporterMu sync.Mutex
+	goImporter   = importer.Default()
+)
+
+// goLinter parses and type-checks Go files. Through LintFS the file is
+// checked together with the rest of its package, so identifiers declared in
+// other files resolve.
+type goLinter struct{}
+
+func (goLinter) Lint(path string, content []byte) []Diagnostic {
+	return lintGo(nil, path, content)
+}
+
+func (goLinter) LintFS(fsys FS, path string, content []byte) []Diagnostic {
+	return lintGo(fsys, path, content)
+}
+
+func lintGo(fsys FS, path string, content []byte) []Diagnostic {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.AllErrors)
+	if err != nil {
+		var list scanner.ErrorList
+		if errors.As(err, &list) {
+			diagnostics := make([]Diagnostic, 0, len(list))
+			for _, e := range list {
+				diagnostics = append(diagnostics, Diagnostic{Line: e.Pos.Line, Column: e.Pos.Column, Message: e.Msg})
+			}
+			return diagnostics
+		}
+		return []Diagnostic{{Message: err.Error()}}
+	}
+	files := []*ast.File{file}
+	if fsys != nil {
+		files = append(files, goPackageFiles(fsys, fset, path, file.Name.Name)...)
+	}
+
+	goImporterMu.Lock()
+	defer goImporterMu.Unlock()
+	diagnostics := make([]Diagnostic, 0)
+	conf := types.Config{
+		Importer: goImporter,
+		Error: func(err error) {
+			var typeErr types.Error
+			if !errors.As(err, &typeErr) {
+				return
+			}
+			// Only the standard library can be imported, so missing
+			// third-party and module-local packages are not the edit's fault.
+			if strings.HasPrefix(typeErr.Msg, "could not import ") {
+				importPath := strings.Fields(strings.TrimPrefix(typeErr.Msg, "could not import "))[0]
+				if !isStdlibImport(importPath) {
+					return
+				}
+			}
+			position := fset.Position(typeErr.Pos)
+			if position.Filename != path {
+				// Problems in the rest of the package, such as calls to a
+				// function the edit removed, name the file they are in.
+				diagnostics = append(diagnostics, Diagnostic{Message: fmt.Sprintf("%s:%d:%d: %s",
+					filepath.Base(position.Filename), position.Line, position.Column, typeErr.Msg)})
+				return
+			}
+			diagnostics = append(diagnostics, Diagnostic{Line: position.Line, Column: position.Column, Message: typeErr.Msg})
+		},
+	}
+	_, _ = conf.Check(file.Name.Name, fset, files, nil)
+	return diagnostics
+}
+
+// goPackageFiles parses the other files of package pkg in the directory of
+// path that the build constraints select for this platform. Test files are
+// only included when path is one. Files that do not parse are skipped, as
+// their errors are not the edit's.
+func goPackageFiles(fsys FS, fset *token.FileSet, path string, pkg string) []*ast.File {
+	dir := filepath.Dir(path)
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	ctxt := build.Default
+	ctxt.OpenFile = func(name string) (io.ReadCloser, error) {
+		data, err := fsys.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	tests := strings.HasSuffix(path, "_test.go")
+	files := make([]*ast.File, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		sibling := filepath.Join(dir, name)
+		if entry.IsDir() || sibling == path || !strings.HasSuffix(name, ".go") ||
+			(!tests && strings.HasSuffix(name, "_test.go")) {
+			continue
+		}
+		if match, err := ctxt.MatchFile(dir, name); err != nil || !match {
+			continue
+		}
+		data, err := fsys.ReadFile(sibling)
+		if err != nil {
+			continue
+		}
+		file, err := parser.ParseFile(fset, sibling, data, 0)
+		if err != nil || file.Name.Name != pkg {
+			continue
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// isStdlibImport guesses whether an import path belongs to the standard
+// library, whose first element never contains a dot.
+func isStdlibImport(importPath string) bool {
+	return !strings.Contains(strings.Split(importPath, "/")[0], ".")
+}
+
+func lintJSON(path string, content []byte) []Diagnostic {
+	if len(bytes.TrimSpace(content)) == 0 || json.Valid(content) {
+		return nil
+	}
+	var value any
+	err := json.Unmarshal(content, &value)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line, column := offsetPosition(content, int(syntaxErr.Offset))
+		return []Diagnostic{{Line: line, Column: column, Message: syntaxErr.Error()}}
+	}
+	if err != nil {
+		return []Diagnostic{{Message: err.Error()}}
+	}
+	return nil
+}
+
+var yamlLine = regexp.MustCompile(`line (\d+): `)
+
+func lintYAML(path string, content []byte) []Diagnostic {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var value yaml.Node
+		err := decoder.Decode(&value)
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		message := strings.TrimPrefix(err.Error(), "yaml: ")
+		diagnostic := Diagnostic{Message: yamlLine.ReplaceAllString(message, "")}
+		if match := yamlLine.FindStringSubmatch(message); match != nil {
+			diagnostic.Line, _ = strconv.Atoi(match[1])
+		}
+		return []Diagnostic{diagnostic}
+	}
+}
+
+// offsetPosition converts a byte offset into a 1-based line and column.
+func offsetPosition(content []byte, offset int) (line int, column int) {
+	offset = min(max(offset, 0), len(content))
+	before := content[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
@@ -0,0 +1,60 @@
+package base
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLintGoSeesWholePackage(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.go"), "package p\n\nfunc helper() int { return 1 }\n")
+	writeFile(t, filepath.Join(dir, "b.go"), "package p\n\nfunc use() int {\n\treturn 0\n}\n")
+	file, err := NewFileManager(dir).Open("b.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := file.WriteAndRunLint(LineEdit{Start: 4, End: 4, Text: "\treturn helper()"})
+	if result.Error != nil {
+		t.Fatalf("calling a function from another file of the package was rejected: %v", result.Error)
+	}
+	result = file.WriteAndRunLint(LineEdit{Start: 4, End: 4, Text: "\treturn missing()"})
+	var lintErr *LintError
+	if !errors.As(result.Error, &lintErr) {
+		t.Fatalf("calling an undefined function was accepted: %v", result.Error)
+	}
+}
+
+func TestLintGuardKeepsFileOnRejectedEdit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.go")
+	writeFile(t, path, "package p\n")
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := NewFileManager(dir).Open("a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := file.WriteAndRunLint(LineEdit{Start: 5, End: 6, Text: "x"})
+	var rangeErr *LineRangeError
+	if !errors.As(result.Error, &rangeErr) {
+		t.Fatalf("out-of-range edit: got %v, want a LineRangeError", result.Error)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) || !after.ModTime().Equal(before.ModTime()) {
+		t.Error("a rejected edit rewrote the file")
+	}
+}
+
+func writeFile(t testing.TB, path string, text string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,7 +3,11 @@ package base
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -14,34 +18,42 @@ import (
 	"time"
 )
 
-var (
-	activeManager *FileManager
-	managerLock   sync.Mutex
-)
+type fileManagerKey struct{}
 
-func SetCurrentFileManager(manager *FileManager) {
-	managerLock.Lock()
-	defer managerLock.Unlock()
-	activeManager = manager
+// WithFileManager returns a copy of ctx in which manager is the current file manager.
+// Managers never change the process working directory, so any number of them
+// can be current in different contexts at the same time.
+func WithFileManager(ctx context.Context, manager *FileManager) context.Context {
+	return context.WithValue(ctx, fileManagerKey{}, manager)
 }
 
-func GetCurrentFileManager() *FileManager {
-	managerLock.Lock()
-	defer managerLock.Unlock()
-	return activeManager
+// FileManagerFromContext returns the file manager made current with WithFileManager.
+func FileManagerFromContext(ctx context.Context) (*FileManager, bool) {
+	manager, ok := ctx.Value(fileManagerKey{}).(*FileManager)
+	return manager, ok && manager != nil
 }
 
+// FileManager tracks the open files and working directory of one workspace.
+// It is safe for concurrent use; the exported fields should only be read
+// through the accessor methods while other goroutines may be using it.
 type FileManager struct {
+	mu         sync.RWMutex
+	fs         FS
+	autoFormat bool
+	lineWidth  int
+	charBudget int
 	ID         string
 	WorkingDir string
 	Files      map[string]*File
-	Pwd        string
 	Recent     *File
 }
 
 type Options struct {
 	Recursive       bool
 	CaseInsensitive bool
+	// Archives makes Find and ListFiles look inside zip and tar archives.
+	// It is off by default because every archive has to be decompressed.
+	Archives bool
 }
 type Option func(*Options)
 
@@ -57,60 +69,146 @@ func WithCaseInsensitive(caseInsensitive bool) Option {
 	}
 }
 
-func NewFileManager(workingDir string) *FileManager {
+// WithArchives makes Find and ListFiles descend into archives as if they
+// were directories.
+func WithArchives(archives bool) Option {
+	return func(opts *Options) {
+		opts.Archives = archives
+	}
+}
+
+type ManagerOption func(*FileManager)
+
+// WithCharBudget caps the characters shown by one window of each file the
+// manager opens; see File.CharBudget.
+func WithCharBudget(chars int) ManagerOption {
+	return func(fm *FileManager) {
+		fm.charBudget = chars
+	}
+}
+
+// WithTokenBudget is WithCharBudget with the budget given in estimated model
+// tokens.
+func WithTokenBudget(tokens int) ManagerOption {
+	return WithCharBudget(tokens * CharsPerToken)
+}
+
+// WithMaxLineWidth sets the width at which the manager's files and Grep
+// results truncate long lines; see File.MaxLineWidth.
+func WithMaxLineWidth(width int) ManagerOption {
+	return func(fm *FileManager) {
+		fm.lineWidth = width
+	}
+}
+
+// WithFS makes the manager perform all file I/O through fsys instead of the
+// operating system. The manager wraps it in an ArchiveFS unless it already
+// is one.
+func WithFS(fsys FS) ManagerOption {
+	return func(fm *FileManager) {
+		fm.fs = fsys
+	}
+}
+
+// WithAutoFormat turns on the post-edit formatter stage for every file the
+// manager opens or creates.
+func WithAutoFormat(enabled bool) ManagerOption {
+	return func(fm *FileManager) {
+		fm.autoFormat = enabled
+	}
+}
+
+func NewFileManager(workingDir string, options ...ManagerOption) *FileManager {
 	if workingDir == "" {
 		workingDir, _ = os.Getwd()
 	}
+	if abs, err := filepath.Abs(workingDir); err == nil {
+		workingDir = abs
+	}
 	fm := &FileManager{
+		fs:         OSFS{},
 		ID:         generateID(),
 		WorkingDir: workingDir,
 		Files:      make(map[string]*File),
 	}
-	return fm
-}
-
-func (fm *FileManager) enter() *FileManager {
-	//""
-	//"Enter workspace context."
-	//""
-	activeManager = GetCurrentFileManager()
-	if activeManager != nil && activeManager.ID != fm.ID {
-		panic("Another manager already activated via context.")
+	for _, option := range options {
+		option(fm)
 	}
-	fm.Pwd, _ = os.Getwd()
-	err := os.Chdir(fm.WorkingDir)
-	if err != nil {
-		return nil
+	if _, ok := fm.fs.(*ArchiveFS); !ok {
+		fm.fs = NewArchiveFS(fm.fs)
 	}
-	SetCurrentFileManager(fm)
 	return fm
 }
 
-func (fm *FileManager) exit(args ...any) {
-	//"""Exit from workspace context."""
-	if fm.Pwd != "" {
-		err := os.Chdir(fm.Pwd)
-		if err != nil {
-			return
+// FS returns the filesystem the manager reads and writes through.
+func (fm *FileManager) FS() FS {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return fm.fs
+}
+
+// EnableOverlay switches the manager to copy-on-write mode rooted at its
+// working directory. Writes, creates and deletes go to an in-memory layer, or
+// to scratchDir when it is not empty, until they are committed or discarded
+// through the returned OverlayFS. ExecuteCommand still runs against the
+// real directory.
+func (fm *FileManager) EnableOverlay(scratchDir string) (*OverlayFS, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if overlay, ok := unwrapArchiveFS(fm.fs).(*OverlayFS); ok {
+		return overlay, nil
+	}
+	var upper FS = NewMemFS()
+	if scratchDir != "" {
+		var err error
+		if upper, err = NewScratchFS(scratchDir); err != nil {
+			return nil, err
 		}
-		SetCurrentFileManager(nil)
 	}
+	overlay := NewOverlayFS(unwrapArchiveFS(fm.fs), upper, fm.WorkingDir)
+	fm.fs = NewArchiveFS(overlay)
+	for _, file := range fm.Files {
+		file.mu.Lock()
+		file.fs = fm.fs
+		file.mu.Unlock()
+	}
+	return overlay, nil
+}
+
+// Overlay returns the overlay enabled with EnableOverlay, or nil.
+func (fm *FileManager) Overlay() *OverlayFS {
+	overlay, _ := unwrapArchiveFS(fm.FS()).(*OverlayFS)
+	return overlay
+}
+
+// Cwd returns the current working directory of the manager.
+func (fm *FileManager) Cwd() string {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return fm.WorkingDir
+}
+
+// RecentFile returns the most recently opened or created file, or nil.
+func (fm *FileManager) RecentFile() *File {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return fm.Recent
 }
 
-func (fm *FileManager) resolveDir(dir string) (abspath string, err error) {
+func resolvePath(workingDir string, dir string) (abspath string, err error) {
 	//"""Resolve a directory path."""
 	if filepath.IsAbs(dir) {
 		abspath, err = filepath.Abs(dir)
 	} else {
-		abspath, err = filepath.Abs(filepath.Join(fm.WorkingDir, dir))
+		abspath, err = filepath.Abs(filepath.Join(workingDir, dir))
 	}
 	return
 }
 
-func (fm *FileManager) resolveDirs(dirs []string) ([]string, error) {
+func resolvePaths(workingDir string, dirs []string) ([]string, error) {
 	results := make([]string, 0)
 	for _, dir := range dirs {
-		temp, err := fm.resolveDir(dir)
+		temp, err := resolvePath(workingDir, dir)
 		if err != nil {
 			return nil, err
 		}
@@ -120,7 +218,9 @@ func (fm *FileManager) resolveDirs(dirs []string) ([]string, error) {
 }
 
 func (fm *FileManager) Chdir(path string) error {
-	newDir, err := fm.resolveDir(path)
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	newDir, err := resolvePath(fm.WorkingDir, path)
 	if err != nil {
 		return fmt.Errorf("invalid path: %v", err)
 	}
@@ -132,7 +232,7 @@ func (fm *FileManager) Chdir(path string) error {
 		return fmt.Errorf("access denied: cannot navigate to '%s'", newDir)
 	}
 
-	if isFile(newDir) {
+	if !isDirFS(fm.fs, newDir) {
 		return fmt.Errorf("'%s' is not a valid directory", newDir)
 	}
 
@@ -141,39 +241,74 @@ func (fm *FileManager) Chdir(path string) error {
 }
 
 func (fm *FileManager) Open(path string) (*File, error) {
-	absPath := filepath.Join(fm.WorkingDir, path)
-	if file, exists := fm.Files[absPath]; exists {
-		return file, nil
-	}
+	return fm.open(path, true)
+}
 
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("file %s does not exist", absPath)
-	}
+// Lookup returns the File for path like Open, but leaves the recent file
+// alone, so reading files on the side does not redirect later edits that
+// rely on RecentFile.
+func (fm *FileManager) Lookup(path string) (*File, error) {
+	return fm.open(path, false)
+}
 
-	file := &File{Path: absPath, Workdir: fm.WorkingDir}
-	fm.Files[absPath] = file
-	fm.Recent = file
+func (fm *FileManager) open(path string, recent bool) (*File, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	absPath, err := resolvePath(fm.WorkingDir, path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %v", err)
+	}
+	file, exists := fm.Files[absPath]
+	if !exists {
+		if _, err := fm.fs.Stat(absPath); errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("file %s does not exist", absPath)
+		}
+		file = fm.newFile(absPath)
+		fm.Files[absPath] = file
+	}
+	if recent {
+		fm.Recent = file
+	}
 	return file, nil
 }
 
+// newFile returns a File for path with the default window and the manager's
+// settings. The caller must hold fm.mu.
+func (fm *FileManager) newFile(path string) *File {
+	file := NewFile(path, fm.WorkingDir, 0)
+	file.fs = fm.fs
+	file.AutoFormat = fm.autoFormat
+	file.MaxLineWidth = fm.lineWidth
+	file.CharBudget = fm.charBudget
+	return file
+}
+
 func (fm *FileManager) Create(path string) (*File, error) {
-	absPath := filepath.Join(fm.WorkingDir, path)
-	newFile, err := os.Create(absPath)
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	absPath, err := resolvePath(fm.WorkingDir, path)
 	if err != nil {
-		return nil, fmt.Errorf("could not create file %s: %v", absPath, err)
+		return nil, fmt.Errorf("invalid path: %v", err)
 	}
-	err = newFile.Close()
+	err = fm.fs.WriteFile(absPath, nil, 0644)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not create file %s: %v", absPath, err)
 	}
 
-	file := NewFile(absPath, fm.WorkingDir, 0)
+	file := fm.newFile(absPath)
 	fm.Files[absPath] = file
 	fm.Recent = file
 	return file, nil
 }
 
 func (fm *FileManager) Grep(word string, pattern string, options ...Option) (map[string][]Match, error) {
+	workingDir, fsys := fm.Cwd(), fm.FS()
+	fm.mu.RLock()
+	width := fm.lineWidth
+	fm.mu.RUnlock()
+	if width == 0 {
+		width = DefaultMaxLineWidth
+	}
 	opts := Options{
 		Recursive:       true,
 		CaseInsensitive: true,
@@ -184,40 +319,58 @@ func (fm *FileManager) Grep(word string, pattern string, options ...Option) (map
 		option(&opts)
 	}
 	if pattern == "" {
-		pattern = fm.WorkingDir
+		pattern = workingDir
 	} else {
-		pattern = filepath.Clean(pattern)
+		resolved, err := resolvePath(workingDir, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path: %v", err)
+		}
+		pattern = resolved
 	}
-	pathsToSearch, err := getPathsToSearch(pattern, opts.Recursive)
+	pathsToSearch, err := getPathsToSearch(fsys, pattern, opts.Recursive)
 	if err != nil {
 		return nil, err
 	}
 	results := make(map[string][]Match)
 	for _, filePath := range pathsToSearch {
-		if isFile(filePath) && filepath.Base(filePath)[0] != '.' {
-			f, err := os.Open(filePath)
+		if isFileFS(fsys, filePath) && filepath.Base(filePath)[0] != '.' {
+			f, err := fsys.Open(filePath)
 			if err != nil {
 				return nil, err
 			}
 
-			scanner := bufio.NewScanner(f)
-			lineNumber := 1
+			relPath, err := filepath.Rel(workingDir, filePath)
+			if err != nil {
+				_ = f.Close()
+				return nil, err
+			}
 
-			for scanner.Scan() {
-				line := scanner.Text()
+			// Binary files are only reported as matching, like grep does, so
+			// their bytes do not end up in the results.
+			reader := bufio.NewReader(f)
+			head, _ := reader.Peek(binarySniffLen)
+			if name, _, _ := detectEncoding(head); name == EncodingBinary {
+				data, err := io.ReadAll(reader)
+				_ = f.Close()
+				if err != nil {
+					return nil, fmt.Errorf("error reading %s: %v", relPath, err)
+				}
+				if strings.Contains(formatWord(string(data), opts.CaseInsensitive), formatWord(word, opts.CaseInsensitive)) {
+					results[relPath] = append(results[relPath], Match{Content: "binary file matches"})
+				}
+				continue
+			}
+			err = scanLines(reader, func(lineNumber int, line string) {
 				if strings.Contains(formatWord(line, opts.CaseInsensitive), formatWord(word, opts.CaseInsensitive)) {
-					relPath, err := filepath.Rel(fm.WorkingDir, filePath)
-					if err != nil {
-						return nil, err
-					}
-					if _, exists := fm.Files[relPath]; !exists {
-						results[relPath] = make([]Match, 1)
-					}
-					results[relPath] = append(results[relPath], Match{Content: strings.TrimSpace(line),
+					results[relPath] = append(results[relPath], Match{Content: truncateLine(strings.TrimSpace(line), width),
 						Lineno: lineNumber})
 				}
-				lineNumber++
+			})
+			if err != nil {
+				_ = f.Close()
+				return nil, fmt.Errorf("error reading %s: %v", relPath, err)
 			}
+			_ = f.Close()
 		}
 	}
 	if len(results) == 0 {
@@ -227,20 +380,25 @@ func (fm *FileManager) Grep(word string, pattern string, options ...Option) (map
 	for _, match := range results {
 		numMatches += len(match)
 	}
-	//fmt.Sprintf("Found %v matches for \"%v\" in %v", numMatches, pattern, fm.WorkingDir)
+	//fmt.Sprintf("Found %v matches for \"%v\" in %v", numMatches, pattern, workingDir)
 	return results, nil
 }
 
-func (fm *FileManager) Find(pattern string, depth int, caseSensitive bool, include []string, exclude []string) ([]string, error) {
-	includePaths, err := fm.resolveDirs(include)
+func (fm *FileManager) Find(pattern string, depth int, caseSensitive bool, include []string, exclude []string, options ...Option) ([]string, error) {
+	workingDir, fsys := fm.Cwd(), fm.FS()
+	var opts Options
+	for _, option := range options {
+		option(&opts)
+	}
+	includePaths, err := resolvePaths(workingDir, include)
 	if err != nil {
 		return nil, err
 	}
 	if len(includePaths) == 0 {
-		includePaths = append(includePaths, fm.WorkingDir)
+		includePaths = append(includePaths, workingDir)
 	}
 	exclude = append(exclude, ".git")
-	excludePaths, err := fm.resolveDirs(exclude)
+	excludePaths, err := resolvePaths(workingDir, exclude)
 	if err != nil {
 		return nil, err
 	}
@@ -257,11 +415,14 @@ func (fm *FileManager) Find(pattern string, depth int, caseSensitive bool, inclu
 		if depth != 0 && currentDepth > depth {
 			return
 		}
-		entries, err := os.ReadDir(directory)
+		entries, err := fsys.ReadDir(directory)
 		if err != nil {
-			if os.IsPermission(err) {
+			if errors.Is(err, fs.ErrPermission) {
 				return // 跳过没有权限访问的目录
 			}
+			if strings.HasSuffix(directory, ArchiveSeparator) {
+				return // a file named like an archive that is not one
+			}
 			fmt.Println("Error reading directory:", err)
 			return
 		}
@@ -293,7 +454,7 @@ func (fm *FileManager) Find(pattern string, depth int, caseSensitive bool, inclu
 				continue
 			}
 
-			relativePath, err := filepath.Rel(fm.WorkingDir, absItemPath)
+			relativePath, err := filepath.Rel(workingDir, absItemPath)
 			if err != nil {
 				fmt.Println("Error getting relative path:", err)
 				continue
@@ -305,6 +466,8 @@ func (fm *FileManager) Find(pattern string, depth int, caseSensitive bool, inclu
 
 			if entry.IsDir() {
 				searchRecursive(absItemPath, currentDepth+1)
+			} else if opts.Archives && IsArchive(entry.Name()) {
+				searchRecursive(absItemPath+ArchiveSeparator, currentDepth+1)
 			}
 		}
 	}
@@ -322,23 +485,20 @@ func formatWord(word string, caseInsensitive bool) string {
 	return word
 }
 
-func getPathsToSearch(pattern string, recursive bool) (pathsToSearch []string, err error) {
+func getPathsToSearch(fsys FS, pattern string, recursive bool) (pathsToSearch []string, err error) {
 	// 获取要搜索的路径
 	pathsToSearch = make([]string, 0)
-	if isFile(pattern) && err == nil {
+	if isDirFS(fsys, pattern) {
 		if recursive {
-			err = filepath.Walk(pattern, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
+			err = walkFS(fsys, pattern, func(path string, entry fs.DirEntry) error {
 				pathsToSearch = append(pathsToSearch, path)
 				return nil
 			})
 		} else {
-			pathsToSearch, err = filepath.Glob(pattern)
+			pathsToSearch, err = fs.Glob(fsys, filepath.Join(pattern, "*"))
 		}
-	} else if err == nil {
-		pathsToSearch = append(pathsToSearch, pattern)
+	} else {
+		pathsToSearch, err = fs.Glob(fsys, pattern)
 	}
 	return
 }
@@ -354,19 +514,19 @@ func (fm *FileManager) tree(
 		return ""
 	}
 	tree := ""
-	childs, err := os.ReadDir(directory)
+	childs, err := fm.FS().ReadDir(directory)
 	if err != nil {
 		fmt.Println("Error reading directory:", err)
 	}
 	for _, child := range childs {
 		path := filepath.Join(directory, child.Name())
-		if isFile(path) && err == nil {
+		if !child.IsDir() {
 			tree += strings.Repeat("  |", level) + "__ " + filepath.Base(path) + "\n"
 		}
 	}
 	for _, child := range childs {
 		path := filepath.Join(directory, child.Name())
-		if info, err := os.Stat(path); info.IsDir() && err == nil {
+		if !child.IsDir() {
 			continue
 		}
 		tree += strings.Repeat("  |", level) + "__ " + filepath.Base(path) + "\n"
@@ -379,13 +539,15 @@ func (fm *FileManager) Tree(
 	exclude []string,
 ) string {
 	//"""
-	//Create directory tree for the file
+	//Create directory tree for the file. Each directory lists its files
+	//once, then its subdirectories with their contents. Symlinks are listed
+	//but not followed, so a link cannot make the tree loop.
 	//
 	//:param depth: Max depth for the tree
 	//:param exclude: Exclude directories from the tree
 	//"""
 	return fm.tree(
-		fm.WorkingDir,
+		fm.Cwd(),
 		0,
 		depth,
 		exclude,
@@ -395,7 +557,7 @@ func (fm *FileManager) Tree(
 func (fm *FileManager) ls() [][2]string {
 	//"""List contents of the current directory with their types."""
 	result := make([][2]string, 0)
-	childs, err := os.ReadDir(fm.WorkingDir)
+	childs, err := fm.FS().ReadDir(fm.Cwd())
 	if err != nil {
 		return nil
 	}
@@ -410,11 +572,81 @@ func (fm *FileManager) ls() [][2]string {
 	return result
 }
 
+// FileEntry is a file, directory or archive listed by ListFiles.
+type FileEntry struct {
+	// Path is relative to the working directory; archive members are listed
+	// as "archive.zip!/member".
+	Path string
+	// Type is "file", "dir" or "archive"; archives are only told apart
+	// with WithArchives.
+	Type string
+	Size int64
+}
+
+// ListFiles lists the directory or archive at path, the working directory
+// when it is empty. Subdirectories, and archives with WithArchives, are
+// descended into up to depth levels; 0 lists only path itself and -1 has no
+// limit. Files named like archives that do not open are listed as files.
+func (fm *FileManager) ListFiles(path string, depth int, options ...Option) ([]FileEntry, error) {
+	workingDir, fsys := fm.Cwd(), fm.FS()
+	var opts Options
+	for _, option := range options {
+		option(&opts)
+	}
+	root, err := resolvePath(workingDir, path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %v", err)
+	}
+	if !isDirFS(fsys, root) && IsArchive(root) {
+		root += ArchiveSeparator
+	}
+	if !isDirFS(fsys, root) {
+		return nil, fmt.Errorf("'%s' is not a valid directory or archive", root)
+	}
+	entries := make([]FileEntry, 0)
+	var list func(directory string, level int) error
+	list = func(directory string, level int) error {
+		children, err := fsys.ReadDir(directory)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			childPath := filepath.Join(directory, child.Name())
+			relPath, err := filepath.Rel(workingDir, childPath)
+			if err != nil {
+				relPath = childPath
+			}
+			entry := FileEntry{Path: relPath, Type: "file"}
+			next := ""
+			switch {
+			case child.IsDir():
+				entry.Type, next = "dir", childPath
+			case opts.Archives && IsArchive(child.Name()) && isDirFS(fsys, childPath+ArchiveSeparator):
+				entry.Type, next = "archive", childPath+ArchiveSeparator
+			}
+			if info, err := child.Info(); err == nil && !child.IsDir() {
+				entry.Size = info.Size()
+			}
+			entries = append(entries, entry)
+			if next != "" && (depth == -1 || level < depth) {
+				if err := list(next, level+1); err != nil {
+					return fmt.Errorf("could not list %s: %v", relPath, err)
+				}
+			}
+		}
+		return nil
+	}
+	if err := list(root, 0); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
 func (fm *FileManager) ExecuteCommand(command string) (string, error) {
 	//"""Execute a command in the current working directory."""
 	// 创建命令对象
 	cmd := exec.Command("bash", "-c", command)
-	cmd.Dir = fm.WorkingDir
+	cmd.Dir = fm.Cwd()
 
 	// 捕获输出
 	var out bytes.Buffer
@@ -0,0 +1,269 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// These tests are meant to be run with -race; they check that parallel tool
+// calls neither race nor lose each other's edits.
+
+const workers = 16
+
+func numberedFile(t *testing.T, path string, n int) {
+	t.Helper()
+	var text strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&text, "value %02d\n", i)
+	}
+	writeFile(t, path, text.String())
+}
+
+func TestConcurrentOpenReturnsOneFile(t *testing.T) {
+	dir := t.TempDir()
+	numberedFile(t, filepath.Join(dir, "a.txt"), 10)
+	fm := NewFileManager(dir)
+	files := make([]*File, workers)
+	var wg sync.WaitGroup
+	for i := range files {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			file, err := fm.Open("a.txt")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			files[i] = file
+		}()
+	}
+	wg.Wait()
+	for _, file := range files {
+		if file != files[0] {
+			t.Fatal("concurrent opens of one path returned different files")
+		}
+	}
+	if fm.RecentFile() != files[0] {
+		t.Error("RecentFile is not the opened file")
+	}
+}
+
+func TestConcurrentEditsSerialize(t *testing.T) {
+	dir := t.TempDir()
+	numberedFile(t, filepath.Join(dir, "a.txt"), 2*workers)
+	fm := NewFileManager(dir)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		// Line edits and replacements of different lines of one file must
+		// all survive, which needs them to run one after the other.
+		go func() {
+			defer wg.Done()
+			file, err := fm.Open("a.txt")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if result := file.Edit(fmt.Sprintf("edited %02d", i), i+1, i+1, ScopeFile); result.Error != nil {
+				t.Error(result.Error)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			file, err := fm.Open("a.txt")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			n := workers + i
+			if result := file.Replace(fmt.Sprintf("value %02d", n), fmt.Sprintf("replaced %02d", n)); result.Error != nil {
+				t.Error(result.Error)
+			}
+		}()
+	}
+	wg.Wait()
+
+	file, err := fm.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, err := file.ReadRange(0, 2*workers, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2*workers; i++ {
+		want := fmt.Sprintf("edited %02d", i)
+		if i >= workers {
+			want = fmt.Sprintf("replaced %02d", i)
+		}
+		if got := view.Lines[i+1]; got != want {
+			t.Errorf("line %d = %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestConcurrentReadsAndEditsOfDifferentFiles(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 4; i++ {
+		numberedFile(t, filepath.Join(dir, fmt.Sprintf("f%d.txt", i)), 200)
+	}
+	fm := NewFileManager(dir)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("f%d.txt", i%4)
+			file, err := fm.Open(name)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			switch i % 4 {
+			case 0:
+				file.Scroll(10, ScrollDown)
+				if _, err := file.View(); err != nil {
+					t.Error(err)
+				}
+			case 1:
+				results, err := fm.Grep("value 1", "")
+				if err != nil {
+					t.Error(err)
+				} else if len(results) == 0 {
+					t.Error("Grep of the working directory found no matches")
+				}
+			case 2:
+				if result := file.Edit("changed", 1, 1, ScopeFile); result.Error != nil {
+					t.Error(result.Error)
+				}
+			case 3:
+				if _, err := file.Find("value", ScopeWindow); err != nil {
+					t.Error(err)
+				}
+				_ = file.TotalLines()
+			}
+			_ = fm.RecentFile()
+			_ = fm.Cwd()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLookupKeepsRecentFile(t *testing.T) {
+	dir := t.TempDir()
+	numberedFile(t, filepath.Join(dir, "a.txt"), 1)
+	numberedFile(t, filepath.Join(dir, "b.txt"), 1)
+	fm := NewFileManager(dir)
+	a, err := fm.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := fm.Lookup("b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fm.RecentFile() != a {
+		t.Error("Lookup changed the recent file")
+	}
+	if opened, _ := fm.Open("b.txt"); opened != b {
+		t.Error("Open after Lookup returned a different file")
+	}
+}
+
+func TestGrepDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "needle\nhay\n")
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "sub", "b.txt"), "hay\nneedle\n")
+	writeFile(t, filepath.Join(dir, ".hidden"), "needle\n")
+	fm := NewFileManager(dir)
+
+	for _, tc := range []struct {
+		pattern string
+		options []Option
+		want    map[string]int
+	}{
+		{"", nil, map[string]int{"a.txt": 1, filepath.Join("sub", "b.txt"): 2}},
+		{"", []Option{WithRecursive(false)}, map[string]int{"a.txt": 1}},
+		{"sub", nil, map[string]int{filepath.Join("sub", "b.txt"): 2}},
+		{"*.txt", nil, map[string]int{"a.txt": 1}},
+		{filepath.Join("sub", "b.txt"), nil, map[string]int{filepath.Join("sub", "b.txt"): 2}},
+	} {
+		results, err := fm.Grep("needle", tc.pattern, tc.options...)
+		if err != nil {
+			t.Fatalf("Grep(%q): %v", tc.pattern, err)
+		}
+		got := make(map[string]int)
+		for path, matches := range results {
+			for _, match := range matches {
+				got[path] = match.Lineno
+			}
+		}
+		if !maps.Equal(got, tc.want) {
+			t.Errorf("Grep(%q, %d options) = %v, want %v", tc.pattern, len(tc.options), got, tc.want)
+		}
+	}
+}
+
+func TestManagersInContexts(t *testing.T) {
+	words := []string{"alpha", "beta"}
+	dirs := make([]string, len(words))
+	for i, word := range words {
+		dirs[i] = t.TempDir()
+		if err := os.Mkdir(filepath.Join(dirs[i], "sub"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(dirs[i], "sub", "x.txt"), word+"\n")
+	}
+
+	var wg sync.WaitGroup
+	for i, word := range words {
+		ctx := WithFileManager(context.Background(), NewFileManager(dirs[i]))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fm, ok := FileManagerFromContext(ctx)
+			if !ok {
+				t.Error("no file manager in the context")
+				return
+			}
+			for j := 0; j < 50; j++ {
+				if err := fm.Chdir("sub"); err != nil {
+					t.Error(err)
+					return
+				}
+				if cwd := fm.Cwd(); cwd != filepath.Join(dirs[i], "sub") {
+					t.Errorf("%s: Cwd = %s", word, cwd)
+				}
+				file, err := fm.Open("x.txt")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if lines, err := file.Read(); err != nil || lines[1] != word {
+					t.Errorf("%s: Read = %v, %v", word, lines, err)
+				}
+				if recent := fm.RecentFile(); recent == nil || recent.Path != filepath.Join(dirs[i], "sub", "x.txt") {
+					t.Errorf("%s: RecentFile = %v", word, recent)
+				}
+				results, err := fm.Grep(word, "")
+				if err != nil || len(results) != 1 || len(results["x.txt"]) != 1 {
+					t.Errorf("%s: Grep = %v, %v", word, results, err)
+				}
+				if err := fm.Chdir(".."); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
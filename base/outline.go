@@ -0,0 +1,126 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrNoOutline is returned by Outline for files it cannot outline.
+var ErrNoOutline = errors.New("outline view is only available for Go files")
+
+// Outline renders a Go file with every function body collapsed to
+// "{ ... N lines }", keeping declarations, signatures, doc comments and
+// struct fields. Lines are keyed by their number in the file, so the hidden
+// ones are simply missing. Bodies of functions named in expand are shown in
+// full; an entry is a function name, a method as "Type.Method" or
+// "(*Type).Method", or the number of any line of the function.
+//
+// Like View, the outline stops early when CharBudget runs out; End is then
+// the last line covered, and OutlineFrom continues after it.
+func (f *File) Outline(expand ...string) (View, error) {
+	return f.OutlineFrom(1, expand...)
+}
+
+// OutlineFrom is Outline starting at the 1-based line, for paging through
+// an outline that CharBudget cut short.
+func (f *File) OutlineFrom(line int, expand ...string) (View, error) {
+	if !strings.EqualFold(filepath.Ext(f.Path), ".go") {
+		return View{}, ErrNoOutline
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	content, _, err := f.readText()
+	if err != nil {
+		return View{}, err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, f.Path, content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return View{}, fmt.Errorf("cannot outline %s: %v", filepath.Base(f.Path), err)
+	}
+
+	lines, _ := splitLines(content)
+	outline := make(map[int]string, len(lines))
+	for i, line := range lines {
+		outline[i+1] = truncateLine(strings.TrimSuffix(line, "\r"), f.lineWidth())
+	}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		first := fset.Position(fn.Pos()).Line
+		lbrace, rbrace := fset.Position(fn.Body.Lbrace), fset.Position(fn.Body.Rbrace)
+		if rbrace.Line <= lbrace.Line || expanded(fn, first, rbrace.Line, expand) {
+			continue
+		}
+		line := lines[lbrace.Line-1]
+		outline[lbrace.Line] = fmt.Sprintf("%s{ ... %d lines }", line[:lbrace.Column-1], rbrace.Line-lbrace.Line-1)
+		for hidden := lbrace.Line + 1; hidden <= rbrace.Line; hidden++ {
+			delete(outline, hidden)
+		}
+	}
+
+	start := min(max(line, 1), len(lines)+1)
+	view := View{Lines: make(map[int]string, len(outline)), Start: start - 1, End: len(lines)}
+	for lineno := start; lineno <= len(lines); lineno++ {
+		line, shown := outline[lineno]
+		if !shown {
+			continue
+		}
+		if line, ok := view.fit(line, f.CharBudget); ok {
+			view.Lines[lineno] = line
+			continue
+		}
+		view.End = lineno - 1
+		break
+	}
+	return view, nil
+}
+
+// expanded reports whether fn, which spans lines first..last, is named in
+// expand.
+func expanded(fn *ast.FuncDecl, first int, last int, expand []string) bool {
+	names := []string{fn.Name.Name}
+	if fn.Recv != nil && len(fn.Recv.List) > 0 {
+		recv := fn.Recv.List[0].Type
+		pointer := false
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv, pointer = star.X, true
+		}
+		// Drop type parameters of generic receivers.
+		switch index := recv.(type) {
+		case *ast.IndexExpr:
+			recv = index.X
+		case *ast.IndexListExpr:
+			recv = index.X
+		}
+		if ident, ok := recv.(*ast.Ident); ok {
+			names = append(names, ident.Name+"."+fn.Name.Name, "("+ident.Name+")."+fn.Name.Name)
+			if pointer {
+				names = append(names, "(*"+ident.Name+")."+fn.Name.Name)
+			}
+		}
+	}
+	for _, entry := range expand {
+		entry = strings.TrimSpace(entry)
+		if line, err := strconv.Atoi(entry); err == nil {
+			if line >= first && line <= last {
+				return true
+			}
+			continue
+		}
+		for _, name := range names {
+			if entry == name {
+				return true
+			}
+		}
+	}
+	return false
+}
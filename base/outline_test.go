@@ -0,0 +1,87 @@
+package base
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutlineCollapsesBodiesWithinBudget(t *testing.T) {
+	dir := t.TempDir()
+	var text strings.Builder
+	text.WriteString("package p\n")
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&text, "\nfunc f%02d() int {\n\tx := %d\n\treturn x\n}\n", i, i)
+	}
+	writeFile(t, filepath.Join(dir, "a.go"), text.String())
+	fm := NewFileManager(dir)
+	file, err := fm.Open("a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outline, err := file.Outline("f01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := outline.Lines[3]; got != "func f00() int { ... 2 lines }" {
+		t.Errorf("line 3 = %q, want a collapsed body", got)
+	}
+	if got := outline.Lines[9]; got != "\tx := 1" {
+		t.Errorf("line 9 = %q, want the expanded body of f01", got)
+	}
+
+	file.CharBudget = 100
+	outline, err = file.Outline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !outline.Truncated || outline.Chars > 100 {
+		t.Errorf("outline under a 100 character budget: %d characters, truncated %v", outline.Chars, outline.Truncated)
+	}
+	for lineno := range outline.Lines {
+		if lineno > outline.End {
+			t.Errorf("line %d shown past End %d", lineno, outline.End)
+		}
+	}
+}
+
+func TestOutlinePages(t *testing.T) {
+	dir := t.TempDir()
+	var text strings.Builder
+	text.WriteString("package p\n")
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&text, "\nfunc f%02d() int {\n\treturn %d\n}\n", i, i)
+	}
+	writeFile(t, filepath.Join(dir, "a.go"), text.String())
+	file, err := NewFileManager(dir, WithCharBudget(200)).Open("a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.CharBudget = 0
+	want, err := file.Outline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.CharBudget = 200
+	pages := 0
+	var page View
+	for line := 1; line <= want.End; line = page.End + 1 {
+		if page, err = file.OutlineFrom(line); err != nil {
+			t.Fatal(err)
+		}
+		if page.End < line {
+			t.Fatalf("OutlineFrom(%d) made no progress", line)
+		}
+		for lineno, text := range page.Lines {
+			if lineno < line || want.Lines[lineno] != text {
+				t.Errorf("page from line %d shows line %d as %q", line, lineno, text)
+			}
+			delete(want.Lines, lineno)
+		}
+		pages++
+	}
+	if len(want.Lines) != 0 || pages < 2 {
+		t.Errorf("%d pages left %d outline lines unseen", pages, len(want.Lines))
+	}
+}
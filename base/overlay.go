@@ -0,0 +1,583 @@
+package base
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeModified ChangeKind = "modified"
+	ChangeDeleted  ChangeKind = "deleted"
+)
+
+// OverlayChange is a file that differs between the overlay and its base.
+type OverlayChange struct {
+	Path string
+	Kind ChangeKind
+}
+
+// OverlayFS is a copy-on-write FS. Reads fall through to base unless the path
+// was written or deleted; writes, creates and deletes only touch the upper
+// layer, until Commit applies them to base or Discard drops them.
+type OverlayFS struct {
+	mu      sync.RWMutex
+	base    FS
+	upper   FS
+	root    string
+	deleted map[string]bool
+}
+
+// NewOverlayFS layers upper over base. Paths in diffs are shown relative to
+// root.
+func NewOverlayFS(base FS, upper FS, root string) *OverlayFS {
+	return &OverlayFS{
+		base:    base,
+		upper:   upper,
+		root:    filepath.Clean(root),
+		deleted: make(map[string]bool),
+	}
+}
+
+// NewScratchFS returns an FS that stores every absolute path under dir on
+// the operating system, for use as an on-disk overlay layer.
+func NewScratchFS(dir string) (FS, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create scratch directory %s: %v", dir, err)
+	}
+	return &scratchFS{dir: dir}, nil
+}
+
+type scratchFS struct {
+	dir string
+}
+
+func (s *scratchFS) path(name string) string {
+	return filepath.Join(s.dir, filepath.Clean(name))
+}
+
+func (s *scratchFS) Open(name string) (fs.File, error) {
+	return os.Open(s.path(name))
+}
+
+func (s *scratchFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(s.path(name))
+}
+
+func (s *scratchFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(s.path(name))
+}
+
+func (s *scratchFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(s.path(name))
+}
+
+func (s *scratchFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return writeFileAtomic(s.path(name), data, perm)
+}
+
+func (s *scratchFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(s.path(name), perm)
+}
+
+func (s *scratchFS) Remove(name string) error {
+	return os.Remove(s.path(name))
+}
+
+func (s *scratchFS) Rename(oldname string, newname string) error {
+	return os.Rename(s.path(oldname), s.path(newname))
+}
+
+func (s *scratchFS) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(s.path(name), mode)
+}
+
+// hidden reports whether name or one of its parents was deleted in the
+// overlay. The caller must hold o.mu.
+func (o *OverlayFS) hidden(name string) bool {
+	for dir := name; ; dir = filepath.Dir(dir) {
+		if o.deleted[dir] {
+			return true
+		}
+		if filepath.Dir(dir) == dir {
+			return false
+		}
+	}
+}
+
+func (o *OverlayFS) layer(name string) (FS, error) {
+	name = filepath.Clean(name)
+	if _, err := o.upper.Stat(name); err == nil {
+		return o.upper, nil
+	}
+	if o.hidden(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return o.base, nil
+}
+
+func (o *OverlayFS) Open(name string) (fs.File, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	info, err := o.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := o.readDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &memDir{info: info, entries: entries}, nil
+	}
+	layer, err := o.layer(name)
+	if err != nil {
+		return nil, err
+	}
+	return layer.Open(name)
+}
+
+func (o *OverlayFS) Stat(name string) (fs.FileInfo, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.stat(name)
+}
+
+func (o *OverlayFS) stat(name string) (fs.FileInfo, error) {
+	layer, err := o.layer(name)
+	if err != nil {
+		return nil, err
+	}
+	return layer.Stat(name)
+}
+
+func (o *OverlayFS) ReadFile(name string) ([]byte, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	layer, err := o.layer(name)
+	if err != nil {
+		return nil, err
+	}
+	return layer.ReadFile(name)
+}
+
+func (o *OverlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.readDir(name)
+}
+
+func (o *OverlayFS) readDir(name string) ([]fs.DirEntry, error) {
+	name = filepath.Clean(name)
+	merged := make(map[string]fs.DirEntry)
+	upperEntries, upperErr := o.upper.ReadDir(name)
+	var baseErr error
+	if o.hidden(name) {
+		baseErr = &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	} else {
+		var baseEntries []fs.DirEntry
+		baseEntries, baseErr = o.base.ReadDir(name)
+		for _, entry := range baseEntries {
+			if !o.deleted[filepath.Join(name, entry.Name())] {
+				merged[entry.Name()] = entry
+			}
+		}
+	}
+	if upperErr != nil && baseErr != nil {
+		return nil, baseErr
+	}
+	for _, entry := range upperEntries {
+		merged[entry.Name()] = entry
+	}
+	entries := make([]fs.DirEntry, 0, len(merged))
+	for _, entry := range merged {
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+// prepare makes sure the parent of name exists in the upper layer, copying
+// directories up from the merged view. The caller must hold o.mu for writing.
+func (o *OverlayFS) prepare(op string, name string) error {
+	parent := filepath.Dir(name)
+	info, err := o.stat(parent)
+	if err != nil || !info.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return o.upper.MkdirAll(parent, info.Mode().Perm())
+}
+
+func (o *OverlayFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	name = filepath.Clean(name)
+	if err := o.prepare("write", name); err != nil {
+		return err
+	}
+	// Like the OS, keep the permissions of a file that already exists.
+	if info, err := o.stat(name); err == nil {
+		if info.IsDir() {
+			return &fs.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+		}
+		perm = info.Mode().Perm()
+	}
+	if err := o.upper.WriteFile(name, data, perm); err != nil {
+		return err
+	}
+	delete(o.deleted, name)
+	return o.upper.Chmod(name, perm)
+}
+
+func (o *OverlayFS) MkdirAll(name string, perm fs.FileMode) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	name = filepath.Clean(name)
+	if info, err := o.stat(name); err == nil && !info.IsDir() {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: errors.New("not a directory")}
+	}
+	for dir := name; ; dir = filepath.Dir(dir) {
+		if o.deleted[dir] {
+			// A recreated directory starts out empty, so keep hiding what
+			// the base still has inside it.
+			delete(o.deleted, dir)
+			entries, _ := o.base.ReadDir(dir)
+			for _, entry := range entries {
+				o.deleted[filepath.Join(dir, entry.Name())] = true
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return o.upper.MkdirAll(name, perm)
+}
+
+func (o *OverlayFS) Remove(name string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.remove(filepath.Clean(name))
+}
+
+func (o *OverlayFS) remove(name string) error {
+	info, err := o.stat(name)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if info.IsDir() {
+		if entries, _ := o.readDir(name); len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+		}
+	}
+	if _, err := o.upper.Stat(name); err == nil {
+		if err := o.upper.Remove(name); err != nil {
+			return err
+		}
+	}
+	if _, err := o.base.Stat(name); err == nil {
+		o.deleted[name] = true
+	}
+	return nil
+}
+
+func (o *OverlayFS) Rename(oldname string, newname string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	oldname, newname = filepath.Clean(oldname), filepath.Clean(newname)
+	info, err := o.stat(oldname)
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	if info.IsDir() {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: errors.New("renaming directories is not supported in overlay mode")}
+	}
+	layer, _ := o.layer(oldname)
+	data, err := layer.ReadFile(oldname)
+	if err != nil {
+		return err
+	}
+	if err := o.prepare("rename", newname); err != nil {
+		return err
+	}
+	if err := o.upper.WriteFile(newname, data, info.Mode().Perm()); err != nil {
+		return err
+	}
+	delete(o.deleted, newname)
+	return o.remove(oldname)
+}
+
+func (o *OverlayFS) Chmod(name string, mode fs.FileMode) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	name = filepath.Clean(name)
+	info, err := o.stat(name)
+	if err != nil {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+	}
+	if _, err := o.upper.Stat(name); err != nil {
+		if err := o.prepare("chmod", name); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			err = o.upper.MkdirAll(name, mode.Perm())
+		} else {
+			var data []byte
+			if data, err = o.base.ReadFile(name); err == nil {
+				err = o.upper.WriteFile(name, data, mode.Perm())
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return o.upper.Chmod(name, mode)
+}
+
+// Changes lists the files that differ between the overlay and its base,
+// sorted by path.
+func (o *OverlayFS) Changes() ([]OverlayChange, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.changes()
+}
+
+func (o *OverlayFS) changes() ([]OverlayChange, error) {
+	changes := make([]OverlayChange, 0)
+	seen := make(map[string]bool)
+	root := string(filepath.Separator)
+	err := walkFS(o.upper, root, func(path string, entry fs.DirEntry) error {
+		if entry.IsDir() {
+			return nil
+		}
+		seen[path] = true
+		baseInfo, err := o.base.Stat(path)
+		if err != nil || baseInfo.IsDir() || o.hidden(path) {
+			changes = append(changes, OverlayChange{Path: path, Kind: ChangeAdded})
+			return nil
+		}
+		upperData, err := o.upper.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		baseData, err := o.base.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(upperData, baseData) || info.Mode().Perm() != baseInfo.Mode().Perm() {
+			changes = append(changes, OverlayChange{Path: path, Kind: ChangeModified})
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for deleted := range o.deleted {
+		err := walkFS(o.base, deleted, func(path string, entry fs.DirEntry) error {
+			if !entry.IsDir() && !seen[path] {
+				changes = append(changes, OverlayChange{Path: path, Kind: ChangeDeleted})
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	slices.SortFunc(changes, func(a, b OverlayChange) int { return strings.Compare(a.Path, b.Path) })
+	return changes, nil
+}
+
+// session returns the state SaveSession records to restore the overlay.
+func (o *OverlayFS) session() (*sessionOverlay, error) {
+	scratch, ok := o.upper.(*scratchFS)
+	if !ok {
+		return nil, errors.New("an in-memory overlay cannot be saved; commit or discard it, or use a scratch directory")
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	saved := &sessionOverlay{ScratchDir: scratch.dir, Deleted: make([]string, 0, len(o.deleted))}
+	for deleted := range o.deleted {
+		saved.Deleted = append(saved.Deleted, deleted)
+	}
+	slices.Sort(saved.Deleted)
+	return saved, nil
+}
+
+func (o *OverlayFS) label(path string) string {
+	if rel, err := filepath.Rel(o.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+// Diff renders all overlay changes as a unified diff against the base.
+func (o *OverlayFS) Diff() (string, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	changes, err := o.changes()
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	for _, change := range changes {
+		var before, after []byte
+		oldName, newName := "a/"+o.label(change.Path), "b/"+o.label(change.Path)
+		if change.Kind != ChangeAdded {
+			if before, err = o.base.ReadFile(change.Path); err != nil {
+				return "", err
+			}
+		} else {
+			oldName = "/dev/null"
+		}
+		if change.Kind != ChangeDeleted {
+			if after, err = o.upper.ReadFile(change.Path); err != nil {
+				return "", err
+			}
+		} else {
+			newName = "/dev/null"
+		}
+		out.WriteString(UnifiedDiff(oldName, newName, string(before), string(after), 3))
+	}
+	return out.String(), nil
+}
+
+func (o *OverlayFS) selected(paths []string) func(string) bool {
+	if len(paths) == 0 {
+		return func(string) bool { return true }
+	}
+	wanted := make(map[string]bool)
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(o.root, path)
+		}
+		wanted[filepath.Clean(path)] = true
+	}
+	return func(path string) bool { return wanted[path] }
+}
+
+// Commit applies the overlay changes to the base and removes them from the
+// overlay. With no paths every change is committed; otherwise only the
+// changes to the given files, relative to the overlay root or absolute.
+func (o *OverlayFS) Commit(paths ...string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	changes, err := o.changes()
+	if err != nil {
+		return err
+	}
+	selected := o.selected(paths)
+	for _, change := range changes {
+		if !selected(change.Path) {
+			continue
+		}
+		if change.Kind == ChangeDeleted {
+			if err := o.base.Remove(change.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("could not commit deletion of %s: %v", change.Path, err)
+			}
+			continue
+		}
+		info, err := o.upper.Stat(change.Path)
+		if err != nil {
+			return err
+		}
+		data, err := o.upper.ReadFile(change.Path)
+		if err != nil {
+			return err
+		}
+		if err := o.base.MkdirAll(filepath.Dir(change.Path), 0755); err != nil {
+			return fmt.Errorf("could not commit %s: %v", change.Path, err)
+		}
+		if err := o.base.WriteFile(change.Path, data, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("could not commit %s: %v", change.Path, err)
+		}
+		if err := o.base.Chmod(change.Path, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("could not commit %s: %v", change.Path, err)
+		}
+	}
+	if len(paths) == 0 {
+		// Directories deleted in the overlay are removed once their files are gone.
+		for deleted := range o.deleted {
+			_ = o.removeBaseTree(deleted)
+		}
+	}
+	return o.discard(selected, len(paths) == 0)
+}
+
+func (o *OverlayFS) removeBaseTree(root string) error {
+	var dirs []string
+	err := walkFS(o.base, root, func(path string, entry fs.DirEntry) error {
+		if entry.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := o.base.Remove(dirs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Discard drops overlay changes so the base shows through again. With no
+// paths every change is dropped.
+func (o *OverlayFS) Discard(paths ...string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.discard(o.selected(paths), len(paths) == 0)
+}
+
+func (o *OverlayFS) discard(selected func(string) bool, all bool) error {
+	var files, dirs []string
+	root := string(filepath.Separator)
+	err := walkFS(o.upper, root, func(path string, entry fs.DirEntry) error {
+		if entry.IsDir() {
+			if path != root {
+				dirs = append(dirs, path)
+			}
+		} else if selected(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, path := range files {
+		if err := o.upper.Remove(path); err != nil {
+			return err
+		}
+	}
+	if all {
+		for i := len(dirs) - 1; i >= 0; i-- {
+			_ = o.upper.Remove(dirs[i])
+		}
+		clear(o.deleted)
+		return nil
+	}
+	for deleted := range o.deleted {
+		if selected(deleted) {
+			delete(o.deleted, deleted)
+		}
+	}
+	return nil
+}
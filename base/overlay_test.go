@@ -0,0 +1,261 @@
+package base
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// overlayWorkspace returns a manager in overlay mode over a directory holding
+// a.txt, b.txt and sub/x.txt. The overlay layer lives in memory, or in a
+// scratch directory when scratch is set.
+func overlayWorkspace(t *testing.T, scratch bool) (string, *FileManager, *OverlayFS) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "one\ntwo\nthree\n")
+	writeFile(t, filepath.Join(dir, "b.txt"), "needle\n")
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "sub", "x.txt"), "x\n")
+	fm := NewFileManager(dir)
+	scratchDir := ""
+	if scratch {
+		scratchDir = t.TempDir()
+	}
+	overlay, err := fm.EnableOverlay(scratchDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, fm, overlay
+}
+
+// changeOverlay modifies a.txt, deletes b.txt and adds c.txt.
+func changeOverlay(t *testing.T, dir string, fm *FileManager) {
+	t.Helper()
+	a, err := fm.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := a.Write("one\nTWO\nthree\n"); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	if err := fm.FS().Remove(filepath.Join(dir, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	c, err := fm.Create("c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := c.Write("needle\n"); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+}
+
+func onDisk(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "<missing>"
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func changedPaths(t *testing.T, dir string, overlay *OverlayFS) []string {
+	t.Helper()
+	changes, err := overlay.Changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make([]string, 0, len(changes))
+	for _, change := range changes {
+		rel, _ := filepath.Rel(dir, change.Path)
+		paths = append(paths, string(change.Kind)+" "+filepath.ToSlash(rel))
+	}
+	return paths
+}
+
+func TestOverlayDiff(t *testing.T) {
+	for _, scratch := range []bool{false, true} {
+		dir, fm, overlay := overlayWorkspace(t, scratch)
+		changeOverlay(t, dir, fm)
+
+		if got := onDisk(t, filepath.Join(dir, "a.txt")); got != "one\ntwo\nthree\n" {
+			t.Errorf("scratch=%v: a.txt on disk = %q, want it unchanged", scratch, got)
+		}
+		if got := onDisk(t, filepath.Join(dir, "b.txt")); got != "needle\n" {
+			t.Errorf("scratch=%v: b.txt on disk = %q, want it unchanged", scratch, got)
+		}
+		if got := onDisk(t, filepath.Join(dir, "c.txt")); got != "<missing>" {
+			t.Errorf("scratch=%v: c.txt on disk = %q, want it missing", scratch, got)
+		}
+		want := []string{"modified a.txt", "deleted b.txt", "added c.txt"}
+		if got := changedPaths(t, dir, overlay); !slices.Equal(got, want) {
+			t.Errorf("scratch=%v: Changes = %q, want %q", scratch, got, want)
+		}
+
+		diff, err := overlay.Diff()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantDiff := "--- a/a.txt\n+++ b/a.txt\n@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three\n" +
+			"--- a/b.txt\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-needle\n" +
+			"--- /dev/null\n+++ b/c.txt\n@@ -0,0 +1,1 @@\n+needle\n"
+		if diff != wantDiff {
+			t.Errorf("scratch=%v: Diff =\n%s\nwant\n%s", scratch, diff, wantDiff)
+		}
+	}
+}
+
+func TestOverlayCommit(t *testing.T) {
+	dir, fm, overlay := overlayWorkspace(t, false)
+	changeOverlay(t, dir, fm)
+
+	if err := overlay.Commit("a.txt"); err != nil {
+		t.Fatalf("Commit(a.txt): %v", err)
+	}
+	if got := onDisk(t, filepath.Join(dir, "a.txt")); got != "one\nTWO\nthree\n" {
+		t.Errorf("a.txt on disk after committing it = %q", got)
+	}
+	if got := onDisk(t, filepath.Join(dir, "b.txt")); got != "needle\n" {
+		t.Errorf("b.txt on disk after committing only a.txt = %q", got)
+	}
+	want := []string{"deleted b.txt", "added c.txt"}
+	if got := changedPaths(t, dir, overlay); !slices.Equal(got, want) {
+		t.Errorf("Changes after committing a.txt = %q, want %q", got, want)
+	}
+
+	if err := overlay.Commit(); err != nil {
+		t.Fatalf("Commit(): %v", err)
+	}
+	if got := onDisk(t, filepath.Join(dir, "b.txt")); got != "<missing>" {
+		t.Errorf("b.txt on disk after committing everything = %q, want it removed", got)
+	}
+	if got := onDisk(t, filepath.Join(dir, "c.txt")); got != "needle\n" {
+		t.Errorf("c.txt on disk after committing everything = %q", got)
+	}
+	if got := changedPaths(t, dir, overlay); len(got) != 0 {
+		t.Errorf("Changes after committing everything = %q, want none", got)
+	}
+}
+
+func TestOverlayDiscard(t *testing.T) {
+	dir, fm, overlay := overlayWorkspace(t, false)
+	changeOverlay(t, dir, fm)
+
+	if err := overlay.Discard("b.txt"); err != nil {
+		t.Fatalf("Discard(b.txt): %v", err)
+	}
+	if data, err := fm.FS().ReadFile(filepath.Join(dir, "b.txt")); err != nil || string(data) != "needle\n" {
+		t.Errorf("b.txt after discarding its deletion = %q, %v", data, err)
+	}
+	want := []string{"modified a.txt", "added c.txt"}
+	if got := changedPaths(t, dir, overlay); !slices.Equal(got, want) {
+		t.Errorf("Changes after discarding b.txt = %q, want %q", got, want)
+	}
+
+	if err := overlay.Discard(); err != nil {
+		t.Fatalf("Discard(): %v", err)
+	}
+	if data, err := fm.FS().ReadFile(filepath.Join(dir, "a.txt")); err != nil || string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("a.txt after discarding everything = %q, %v", data, err)
+	}
+	if _, err := fm.FS().Stat(filepath.Join(dir, "c.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("c.txt after discarding everything: %v, want it gone", err)
+	}
+	if got := changedPaths(t, dir, overlay); len(got) != 0 {
+		t.Errorf("Changes after discarding everything = %q, want none", got)
+	}
+}
+
+func TestOverlayRecreatedDirectory(t *testing.T) {
+	dir, fm, overlay := overlayWorkspace(t, false)
+	fsys := fm.FS()
+	sub := filepath.Join(dir, "sub")
+	if err := fsys.Remove(filepath.Join(sub, "x.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Remove(sub); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Stat(sub); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat of a deleted directory: %v", err)
+	}
+	if err := fsys.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile(filepath.Join(sub, "y.txt"), []byte("y\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := fsys.ReadDir(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "y.txt" {
+		t.Errorf("recreated directory lists %v, want only y.txt", entries)
+	}
+	want := []string{"deleted sub/x.txt", "added sub/y.txt"}
+	if got := changedPaths(t, dir, overlay); !slices.Equal(got, want) {
+		t.Errorf("Changes = %q, want %q", got, want)
+	}
+
+	if err := overlay.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := onDisk(t, filepath.Join(sub, "x.txt")); got != "<missing>" {
+		t.Errorf("x.txt on disk after commit = %q, want it removed", got)
+	}
+	if got := onDisk(t, filepath.Join(sub, "y.txt")); got != "y\n" {
+		t.Errorf("y.txt on disk after commit = %q", got)
+	}
+}
+
+func TestOverlayMergedView(t *testing.T) {
+	dir, fm, _ := overlayWorkspace(t, false)
+	changeOverlay(t, dir, fm)
+
+	results, err := fm.Grep("needle", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results["c.txt"]) != 1 {
+		t.Errorf("Grep = %v, want a match in c.txt only", results)
+	}
+
+	found, err := fm.Find(`\.txt$`, 0, false, nil, nil)
+	if want := []string{"a.txt", "c.txt", filepath.Join("sub", "x.txt")}; err != nil || !slices.Equal(found, want) {
+		t.Errorf("Find = %v, %v; want %v", found, err, want)
+	}
+
+	tree := fm.Tree(-1, nil)
+	if !strings.Contains(tree, "__ c.txt\n") || strings.Contains(tree, "b.txt") {
+		t.Errorf("Tree does not show the merged view:\n%s", tree)
+	}
+
+	if _, err := fm.Open("b.txt"); err == nil {
+		t.Error("Open of a file deleted in the overlay succeeded")
+	}
+	c, err := fm.Open("c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, err := c.Read()
+	if err != nil || lines[1] != "needle" {
+		t.Errorf("Read of a file added in the overlay = %v, %v", lines, err)
+	}
+	a, err := fm.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines, err := a.Read(); err != nil || lines[2] != "TWO" {
+		t.Errorf("Read of a file modified in the overlay = %v, %v", lines, err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package base
+
+import "io/fs"
+
+// fileOwner reports no owner on systems without Unix user and group IDs.
+func fileOwner(info fs.FileInfo) (uid int, gid int, ok bool) {
+	return 0, 0, false
+}
+
+// fileLinks reports no link count on systems without Unix file metadata.
+func fileLinks(info fs.FileInfo) (links int, ok bool) {
+	return 0, false
+}
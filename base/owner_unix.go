@@ -0,0 +1,26 @@
+//go:build unix
+
+package base
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileOwner returns the user and group that own the file info describes.
+func fileOwner(info fs.FileInfo) (uid int, gid int, ok bool) {
+	sys, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(sys.Uid), int(sys.Gid), true
+}
+
+// fileLinks returns the number of hard links to the file info describes.
+func fileLinks(info fs.FileInfo) (links int, ok bool) {
+	sys, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(sys.Nlink), true
+}
@@ -0,0 +1,228 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrNoMatch is returned when the text to replace does not occur in the file.
+var ErrNoMatch = errors.New("string not found")
+
+// AmbiguousMatchError is returned when the text to replace occurs more than
+// once and neither an occurrence nor replace-all was requested.
+type AmbiguousMatchError struct {
+	Search     string
+	Candidates []Match
+}
+
+func (e *AmbiguousMatchError) Error() string {
+	lines := make([]string, 0, len(e.Candidates))
+	for _, candidate := range e.Candidates {
+		lines = append(lines, fmt.Sprint(candidate.Lineno))
+	}
+	return fmt.Sprintf("%q occurs %d times, at lines %s; pass the occurrence to replace or replace all",
+		e.Search, len(e.Candidates), strings.Join(lines, ", "))
+}
+
+type ReplaceOptions struct {
+	// Occurrence selects the 1-based occurrence to replace when the text is
+	// found more than once.
+	Occurrence int
+	// All replaces every occurrence.
+	All bool
+	// Regex treats the search text as a regular expression; the replacement
+	// may refer to capture groups as $1 or ${name}.
+	Regex bool
+	// Fuzzy lets a literal search that does not occur exactly match whole
+	// lines that differ in whitespace or, failing that, are similar enough.
+	// The replacement is then reindented to the matched lines.
+	Fuzzy bool
+}
+type ReplaceOption func(*ReplaceOptions)
+
+func WithOccurrence(occurrence int) ReplaceOption {
+	return func(opts *ReplaceOptions) {
+		opts.Occurrence = occurrence
+	}
+}
+
+func WithReplaceAll(all bool) ReplaceOption {
+	return func(opts *ReplaceOptions) {
+		opts.All = all
+	}
+}
+
+func WithRegex(regex bool) ReplaceOption {
+	return func(opts *ReplaceOptions) {
+		opts.Regex = regex
+	}
+}
+
+func WithFuzzy(fuzzy bool) ReplaceOption {
+	return func(opts *ReplaceOptions) {
+		opts.Fuzzy = fuzzy
+	}
+}
+
+// Replace replaces search with replacement. By default search must occur
+// exactly once; otherwise the error is an *AmbiguousMatchError listing every
+// candidate with its line number.
+func (f *File) Replace(search string, replacement string, options ...ReplaceOption) TextReplacement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.replace(search, replacement, options...)
+}
+
+// ReplaceAndRunLint is Replace guarded by the linter like WriteAndRunLint.
+func (f *File) ReplaceAndRunLint(search string, replacement string, options ...ReplaceOption) TextReplacement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lintGuard(func() TextReplacement {
+		return f.replace(search, replacement, options...)
+	})
+}
+
+func (f *File) replace(search string, replacement string, options ...ReplaceOption) TextReplacement {
+	opts := ReplaceOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+	if search == "" {
+		return TextReplacement{Error: errors.New("error replacing given string, search string is empty")}
+	}
+	content, format, err := f.readText()
+	if err != nil {
+		return TextReplacement{Error: err}
+	}
+	if err := f.checkText(format); err != nil {
+		return TextReplacement{Error: err}
+	}
+
+	// Each match holds submatch index pairs; the first pair is the whole match.
+	var matches [][]int
+	expand := func(match []int) string { return replacement }
+	strategy, similarity := MatchExact, 1.0
+	if opts.Regex {
+		strategy = MatchRegex
+		re, err := regexp.Compile(search)
+		if err != nil {
+			return TextReplacement{Error: fmt.Errorf("invalid regular expression: %v", err)}
+		}
+		for _, match := range re.FindAllStringSubmatchIndex(content, -1) {
+			if match[0] != match[1] {
+				matches = append(matches, match)
+			}
+		}
+		expand = func(match []int) string {
+			return string(re.ExpandString(nil, replacement, content, match))
+		}
+	} else {
+		for offset := 0; ; {
+			index := strings.Index(content[offset:], search)
+			if index < 0 {
+				break
+			}
+			matches = append(matches, []int{offset + index, offset + index + len(search)})
+			offset += index + len(search)
+		}
+		if len(matches) == 0 && opts.Fuzzy {
+			var fuzzy fuzzyMatch
+			fuzzy, matches = fuzzyMatches(content, search)
+			strategy, similarity = fuzzy.strategy, fuzzy.similarity
+			expand = func(match []int) string {
+				return reindent(replacement, blockIndent(content[match[0]:match[1]]))
+			}
+		}
+	}
+
+	if len(matches) == 0 {
+		return TextReplacement{Error: fmt.Errorf("error replacing given string: %w", ErrNoMatch)}
+	}
+	switch {
+	case opts.All:
+	case opts.Occurrence > 0:
+		if opts.Occurrence > len(matches) {
+			return TextReplacement{Error: fmt.Errorf("occurrence %d requested, but %q occurs %d times",
+				opts.Occurrence, search, len(matches))}
+		}
+		matches = matches[opts.Occurrence-1 : opts.Occurrence]
+	case len(matches) > 1:
+		return TextReplacement{Error: &AmbiguousMatchError{Search: search, Candidates: matchCandidates(content, matches)}}
+	}
+
+	var updated strings.Builder
+	regions := make([]lineRange, 0, len(matches))
+	replaced := make([]string, 0, len(matches))
+	replacements := make([]string, 0, len(matches))
+	cursor, line := 0, 0
+	for _, match := range matches {
+		with := expand(match)
+		updated.WriteString(content[cursor:match[0]])
+		line += strings.Count(content[cursor:match[0]], "\n")
+		regions = append(regions, lineRange{line, line + strings.Count(with, "\n") + 1})
+		updated.WriteString(with)
+		line += strings.Count(with, "\n")
+		replaced = append(replaced, content[match[0]:match[1]])
+		replacements = append(replacements, with)
+		cursor = match[1]
+	}
+	updated.WriteString(content[cursor:])
+
+	result, regions, formatted := f.format(updated.String(), mergeRanges(regions))
+	err = f.writeText(content, result, format)
+	if err != nil {
+		return TextReplacement{Error: err}
+	}
+	response := TextReplacement{
+		ReplacedText: strings.Join(replaced, "\n...\n"),
+		ReplacedWith: strings.Join(replacements, "\n...\n"),
+		Formatted:    formatted,
+		FinalText:    region(result, regions),
+		TotalLines:   lineCount(result),
+		Replacements: len(matches),
+		Strategy:     strategy,
+		Similarity:   similarity,
+		Encoding:     format.encoding,
+	}
+	f.annotate(&response, content, result, regions)
+	return response
+}
+
+// matchCandidates describes each match by its 1-based line, the full line
+// and the match's byte columns within it.
+func matchCandidates(content string, matches [][]int) []Match {
+	candidates := make([]Match, 0, len(matches))
+	for _, match := range matches {
+		lineStart := strings.LastIndexByte(content[:match[0]], '\n') + 1
+		lineEnd := strings.IndexByte(content[lineStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += lineStart
+		}
+		candidates = append(candidates, Match{
+			Content: content[lineStart:lineEnd],
+			Match:   content[match[0]:match[1]],
+			Start:   match[0] - lineStart,
+			End:     match[1] - lineStart,
+			Lineno:  strings.Count(content[:match[0]], "\n") + 1,
+		})
+	}
+	return candidates
+}
+
+// mergeRanges joins sorted ranges that touch, so several replacements on one
+// line are reported once.
+func mergeRanges(ranges []lineRange) []lineRange {
+	merged := make([]lineRange, 0, len(ranges))
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.start < merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, r.end)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
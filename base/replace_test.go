@@ -0,0 +1,86 @@
+package base
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const replaceContent = "let x = 1\nlet y = 2\nprint(x)\nlet x = 3\n"
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		name        string
+		search      string
+		replacement string
+		options     []ReplaceOption
+		want        string
+		count       int
+	}{
+		{"unique", "print(x)", "print(y)", nil, "let x = 1\nlet y = 2\nprint(y)\nlet x = 3\n", 1},
+		{"second occurrence", "let x", "const x", []ReplaceOption{WithOccurrence(2)}, "let x = 1\nlet y = 2\nprint(x)\nconst x = 3\n", 1},
+		{"all", "let", "var", []ReplaceOption{WithReplaceAll(true)}, "var x = 1\nvar y = 2\nprint(x)\nvar x = 3\n", 3},
+		{"regex with a group", `let (\w) = 2`, "const $1 = 2", []ReplaceOption{WithRegex(true)}, "let x = 1\nconst y = 2\nprint(x)\nlet x = 3\n", 1},
+		{"regex, all", `let (\w) = (\d)`, "${1}=$2", []ReplaceOption{WithRegex(true), WithReplaceAll(true)}, "x=1\ny=2\nprint(x)\nx=3\n", 3},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "a.txt")
+		writeFile(t, path, replaceContent)
+		file, err := NewFileManager(dir).Open("a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := file.Replace(test.search, test.replacement, test.options...)
+		if result.Error != nil {
+			t.Fatalf("%s: %v", test.name, result.Error)
+		}
+		if got := onDisk(t, path); got != test.want {
+			t.Errorf("%s: file = %q, want %q", test.name, got, test.want)
+		}
+		if result.Replacements != test.count {
+			t.Errorf("%s: %d replacements, want %d", test.name, result.Replacements, test.count)
+		}
+	}
+}
+
+func TestReplaceFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		search  string
+		options []ReplaceOption
+		check   func(err error) bool
+	}{
+		{"ambiguous", "let x", nil, func(err error) bool {
+			var ambiguous *AmbiguousMatchError
+			if !errors.As(err, &ambiguous) {
+				return false
+			}
+			lines := make([]int, 0, len(ambiguous.Candidates))
+			for _, candidate := range ambiguous.Candidates {
+				lines = append(lines, candidate.Lineno)
+			}
+			return slices.Equal(lines, []int{1, 4})
+		}},
+		{"occurrence out of range", "let x", []ReplaceOption{WithOccurrence(3)}, func(err error) bool { return err != nil }},
+		{"no match", "missing", nil, func(err error) bool { return errors.Is(err, ErrNoMatch) }},
+		{"invalid regex", "let (x", []ReplaceOption{WithRegex(true)}, func(err error) bool { return err != nil }},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "a.txt")
+		writeFile(t, path, replaceContent)
+		file, err := NewFileManager(dir).Open("a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := file.Replace(test.search, "z", test.options...)
+		if !test.check(result.Error) {
+			t.Errorf("%s: unexpected error %v", test.name, result.Error)
+		}
+		if got := onDisk(t, path); got != replaceContent {
+			t.Errorf("%s: file = %q, want it unchanged", test.name, got)
+		}
+	}
+}
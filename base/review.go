@@ -0,0 +1,94 @@
+package base
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// editContextLines is how many unchanged lines are shown around an edit.
+const editContextLines = 3
+
+// annotate fills in the diff, the numbered view of the edited regions of
+// updated and warnings about likely mistakes.
+func (f *File) annotate(r *TextReplacement, original string, updated string, regions []lineRange) {
+	name := f.Path
+	if rel, err := filepath.Rel(f.Workdir, f.Path); err == nil && !strings.HasPrefix(rel, "..") {
+		name = rel
+	}
+	name = filepath.ToSlash(name)
+	r.Diff = UnifiedDiff("a/"+name, "b/"+name, original, updated, editContextLines)
+	r.Context = numberedRegions(updated, regions, editContextLines)
+	r.Warnings = editWarnings(original, updated, regions)
+}
+
+// numberedRegions renders each range of content with context lines around
+// it, prefixed with 1-based line numbers. Ranges whose context overlaps are
+// merged; the others are separated by "...".
+func numberedRegions(content string, regions []lineRange, context int) string {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	var out strings.Builder
+	width := len(fmt.Sprint(len(lines)))
+	last := -1
+	for _, r := range regions {
+		start, end := max(r.start-context, 0), min(r.end+context, len(lines))
+		if last >= 0 && start > last {
+			out.WriteString("...\n")
+		}
+		start = max(start, last)
+		for i := start; i < end; i++ {
+			fmt.Fprintf(&out, "%*d: %s\n", width, i+1, lines[i])
+		}
+		last = max(last, end)
+	}
+	return out.String()
+}
+
+var bracketPairs = [][2]rune{{'(', ')'}, {'[', ']'}, {'{', '}'}}
+
+func editWarnings(original string, updated string, regions []lineRange) []string {
+	warnings := make([]string, 0)
+
+	// Adjacent duplicates that were not in the file before usually mean the
+	// edit range missed a line the replacement repeats.
+	before := adjacentDuplicates(strings.Split(original, "\n"))
+	lines := strings.Split(updated, "\n")
+	for _, r := range regions {
+		for i := max(r.start, 1); i < min(r.end+1, len(lines)); i++ {
+			line := lines[i]
+			if line != lines[i-1] || len(strings.TrimSpace(line)) <= 1 {
+				continue
+			}
+			if before[line] > 0 {
+				before[line]--
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf("line %d duplicates the line above it: %q", i+1, strings.TrimSpace(line)))
+		}
+	}
+
+	for _, pair := range bracketPairs {
+		was := strings.Count(original, string(pair[0])) - strings.Count(original, string(pair[1]))
+		is := strings.Count(updated, string(pair[0])) - strings.Count(updated, string(pair[1]))
+		if was == is {
+			continue
+		}
+		delta := is - was
+		kind := pair[0]
+		if delta < 0 {
+			kind, delta = pair[1], -delta
+		}
+		warnings = append(warnings, fmt.Sprintf("the edit leaves %d unmatched '%c' in the file", delta, kind))
+	}
+	return warnings
+}
+
+func adjacentDuplicates(lines []string) map[string]int {
+	duplicates := make(map[string]int)
+	for i := 1; i < len(lines); i++ {
+		if lines[i] == lines[i-1] && len(strings.TrimSpace(lines[i])) > 1 {
+			duplicates[lines[i]]++
+		}
+	}
+	return duplicates
+}
@@ -0,0 +1,47 @@
+package base
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestEditAnnotations(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		edit     LineEdit
+		context  string
+		warnings []string
+	}{
+		{"clean edit", "a\nb\nc\n", LineEdit{Start: 2, End: 2, Text: "B"}, "1: a\n2: B\n3: c\n", []string{}},
+		{"duplicated line", "a\nfoo()\nb\n", LineEdit{Start: 3, End: 3, Text: "foo()"}, "1: a\n2: foo()\n3: foo()\n",
+			[]string{`line 3 duplicates the line above it: "foo()"`}},
+		{"existing duplicate", "foo()\nfoo()\nb\n", LineEdit{Start: 3, End: 3, Text: "c"}, "1: foo()\n2: foo()\n3: c\n", []string{}},
+		{"unbalanced brackets", "a\nb\n", LineEdit{Start: 2, End: 2, Text: "if (x {"}, "1: a\n2: if (x {\n",
+			[]string{"the edit leaves 1 unmatched '(' in the file", "the edit leaves 1 unmatched '{' in the file"}},
+		{"removed bracket", "f(\n)\n", LineEdit{Mode: EditDelete, Start: 2, End: 2}, "1: f(\n",
+			[]string{"the edit leaves 1 unmatched '(' in the file"}},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "a.txt"), test.content)
+		file, err := NewFileManager(dir).Open("a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := file.ApplyEdit(test.edit, ScopeFile)
+		if result.Error != nil {
+			t.Fatalf("%s: %v", test.name, result.Error)
+		}
+		if result.Context != test.context {
+			t.Errorf("%s: Context = %q, want %q", test.name, result.Context, test.context)
+		}
+		if !slices.Equal(result.Warnings, test.warnings) {
+			t.Errorf("%s: Warnings = %q, want %q", test.name, result.Warnings, test.warnings)
+		}
+		if result.Diff == "" {
+			t.Errorf("%s: no Diff", test.name)
+		}
+	}
+}
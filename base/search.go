@@ -0,0 +1,149 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+type SearchDirection string
+
+const (
+	SearchNext     SearchDirection = "next"
+	SearchPrevious SearchDirection = "previous"
+)
+
+// FindNext finds the match of pattern after (or before) the one it last
+// moved to, or after (before) the start of the window when the window was
+// moved since, and centers the window on it. The search wraps around the
+// end of the file, which wrapped reports. ok is false when nothing matches.
+func (f *File) FindNext(pattern string, direction SearchDirection) (match Match, wrapped bool, ok bool, err error) {
+	if pattern == "" {
+		return Match{}, false, false, errors.New("empty search pattern")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Match{}, false, false, fmt.Errorf("invalid regular expression: %v", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	matches, err := f.findFile(re)
+	if err != nil || len(matches) == 0 {
+		return Match{}, false, false, err
+	}
+
+	from := f.searchFrom
+	if from == nil {
+		// Start just before the first line of the window, so that "next"
+		// finds matches on that line.
+		from = &Match{Lineno: max(f.Start, 0) + 1, Start: -1}
+	}
+	after := func(m Match) bool {
+		return m.Lineno > from.Lineno || (m.Lineno == from.Lineno && m.Start > from.Start)
+	}
+	before := func(m Match) bool {
+		return m.Lineno < from.Lineno || (m.Lineno == from.Lineno && m.Start < from.Start)
+	}
+
+	found := -1
+	switch direction {
+	case SearchPrevious:
+		for i := len(matches) - 1; i >= 0; i-- {
+			if before(matches[i]) {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			found, wrapped = len(matches)-1, true
+		}
+	case SearchNext, "":
+		for i, m := range matches {
+			if after(m) {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			found, wrapped = 0, true
+		}
+	default:
+		return Match{}, false, false, fmt.Errorf("unknown search direction %q, use %q or %q", direction, SearchNext, SearchPrevious)
+	}
+
+	match = matches[found]
+	f.searchFrom = &match
+	f.Start = max(match.Lineno-1-f.Window/2, 0)
+	if f.CharBudget > 0 {
+		ix, err := f.loadIndex()
+		if err != nil {
+			return Match{}, false, false, err
+		}
+		if f.Start, err = f.budgetStart(ix, f.Start, match.Lineno-1); err != nil {
+			return Match{}, false, false, err
+		}
+	}
+	f.End = f.Start + f.Window
+	return match, wrapped, true, nil
+}
+
+// budgetStart moves start, the first line of a window centered on line,
+// forward until the lines before line take at most half the character
+// budget, so the view keeps line and the lines after it.
+func (f *File) budgetStart(ix *lineIndex, start int, line int) (int, error) {
+	lines, err := f.readLines(ix, start, line)
+	if err != nil {
+		return start, err
+	}
+	width := f.lineWidth()
+	before := 0
+	first := start + len(lines)
+	for first > start {
+		chars := utf8.RuneCountInString(truncateLine(lines[first-1-start], width))
+		if before+chars > f.CharBudget/2 {
+			break
+		}
+		before += chars
+		first--
+	}
+	return first, nil
+}
+
+// Excerpt returns lines around the 1-based line, with context lines on
+// either side, each prefixed with its line number and cut at MaxLineWidth
+// like View does.
+func (f *File) Excerpt(line int, context int) (string, error) {
+	excerpts, err := f.Excerpts([]int{line}, context)
+	if err != nil {
+		return "", err
+	}
+	return excerpts[0], nil
+}
+
+// Excerpts returns an Excerpt for each of the 1-based lines, reading the
+// file once for all of them.
+func (f *File) Excerpts(lines []int, context int) ([]string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	ix, err := f.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	numberWidth, lineWidth := len(fmt.Sprint(ix.lineCount())), f.lineWidth()
+	excerpts := make([]string, 0, len(lines))
+	for _, line := range lines {
+		start := max(line-1-context, 0)
+		texts, err := f.readLines(ix, start, line+context)
+		if err != nil {
+			return nil, err
+		}
+		var out strings.Builder
+		for i, text := range texts {
+			fmt.Fprintf(&out, "%*d: %s\n", numberWidth, start+i+1, truncateLine(text, lineWidth))
+		}
+		excerpts = append(excerpts, out.String())
+	}
+	return excerpts, nil
+}
@@ -0,0 +1,88 @@
+package base
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSearchRefusesBinaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.bin"), "match\x00\x01\x02match")
+	file, err := NewFileManager(dir).Open("a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Find("match", ScopeFile); !errors.Is(err, ErrBinaryFile) {
+		t.Errorf("Find: got %v, want ErrBinaryFile", err)
+	}
+	if _, _, _, err := file.FindNext("match", SearchNext); !errors.Is(err, ErrBinaryFile) {
+		t.Errorf("FindNext: got %v, want ErrBinaryFile", err)
+	}
+}
+
+func TestFindNextKeepsMatchInBudget(t *testing.T) {
+	dir := t.TempDir()
+	var text strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&text, "%s %02d\n", strings.Repeat("x", 50), i)
+	}
+	text.WriteString("needle\n")
+	writeFile(t, filepath.Join(dir, "a.txt"), text.String())
+	file, err := NewFileManager(dir, WithCharBudget(300)).Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	match, _, ok, err := file.FindNext("needle", SearchNext)
+	if err != nil || !ok {
+		t.Fatalf("FindNext: %v, %v", ok, err)
+	}
+	view, err := file.View()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.Lines[match.Lineno] != "needle" {
+		t.Errorf("the view of lines %d-%d does not show the match on line %d", view.Start+1, view.End, match.Lineno)
+	}
+}
+
+func TestExcerpts(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n")
+	file, err := NewFileManager(dir).Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	excerpts, err := file.Excerpts([]int{1, 11}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{" 1: a\n 2: b\n", "10: j\n11: k\n"}; excerpts[0] != want[0] || excerpts[1] != want[1] {
+		t.Errorf("Excerpts = %q, want %q", excerpts, want)
+	}
+}
+
+func TestSearchTruncatesLongLines(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "min.js"), "short\n"+strings.Repeat("x", 5000)+"needle\n")
+	file, err := NewFileManager(dir, WithMaxLineWidth(100)).Open("min.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	excerpt, err := file.Excerpt(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1: short\n2: " + truncateLine(strings.Repeat("x", 5000)+"needle", 100) + "\n"
+	if excerpt != want {
+		t.Errorf("Excerpt is %d bytes, want the long line cut at 100 characters", len(excerpt))
+	}
+	if text := file.TruncateLine(strings.Repeat("x", 5000)); len(text) > 200 {
+		t.Errorf("TruncateLine returned %d bytes", len(text))
+	}
+	if _, _, _, err := file.FindNext("", SearchNext); err == nil {
+		t.Error("FindNext accepted an empty pattern")
+	}
+}
@@ -0,0 +1,207 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+type FileStatus string
+
+const (
+	FileUnchanged FileStatus = "unchanged"
+	FileChanged   FileStatus = "changed"
+	FileMissing   FileStatus = "missing"
+)
+
+// RestoredFile reports how an open file from a saved session compares with
+// the file currently on disk.
+type RestoredFile struct {
+	Path   string
+	Status FileStatus
+}
+
+type sessionFile struct {
+	Path         string    `json:"path"`
+	Workdir      string    `json:"workdir"`
+	Start        int       `json:"start"`
+	End          int       `json:"end"`
+	Window       int       `json:"window"`
+	AutoFormat   bool      `json:"auto_format,omitempty"`
+	MaxLineWidth int       `json:"max_line_width,omitempty"`
+	CharBudget   int       `json:"char_budget,omitempty"`
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"mod_time"`
+	Hash         string    `json:"hash"`
+	// Seen is the fingerprint the file reported when the session was saved,
+	// which may differ from Hash if the file changed after it was viewed.
+	Seen *Fingerprint `json:"seen,omitempty"`
+}
+
+type sessionOverlay struct {
+	ScratchDir string   `json:"scratch_dir"`
+	Deleted    []string `json:"deleted,omitempty"`
+}
+
+type session struct {
+	ID         string          `json:"id"`
+	WorkingDir string          `json:"working_dir"`
+	AutoFormat bool            `json:"auto_format,omitempty"`
+	LineWidth  int             `json:"line_width,omitempty"`
+	CharBudget int             `json:"char_budget,omitempty"`
+	Overlay    *sessionOverlay `json:"overlay,omitempty"`
+	Recent     string          `json:"recent,omitempty"`
+	Files      []sessionFile   `json:"files"`
+}
+
+// SaveSession writes the manager's ID, working directory and options, its
+// overlay, open files with their windows, settings and last seen
+// fingerprints, and the recent file to path as JSON. Tail and search
+// positions are not saved. An overlay can only be saved when it keeps its
+// changes in a scratch directory; an in-memory one fails with an error, as
+// its changes would not outlive the process.
+func (fm *FileManager) SaveSession(path string) error {
+	fm.mu.RLock()
+	snapshot := session{
+		ID:         fm.ID,
+		WorkingDir: fm.WorkingDir,
+		AutoFormat: fm.autoFormat,
+		LineWidth:  fm.lineWidth,
+		CharBudget: fm.charBudget,
+		Files:      make([]sessionFile, 0, len(fm.Files)),
+	}
+	if fm.Recent != nil {
+		snapshot.Recent = fm.Recent.Path
+	}
+	fsys := fm.fs
+	overlay, _ := unwrapArchiveFS(fm.fs).(*OverlayFS)
+	files := make([]*File, 0, len(fm.Files))
+	for _, file := range fm.Files {
+		files = append(files, file)
+	}
+	fm.mu.RUnlock()
+
+	if overlay != nil {
+		saved, err := overlay.session()
+		if err != nil {
+			return fmt.Errorf("could not save session %s: %v", path, err)
+		}
+		snapshot.Overlay = saved
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	for _, file := range files {
+		file.mu.RLock()
+		entry := sessionFile{
+			Path:         file.Path,
+			Workdir:      file.Workdir,
+			Start:        file.Start,
+			End:          file.End,
+			Window:       file.Window,
+			AutoFormat:   file.AutoFormat,
+			MaxLineWidth: file.MaxLineWidth,
+			CharBudget:   file.CharBudget,
+			Seen:         file.seen.Load(),
+		}
+		file.mu.RUnlock()
+		// A file that vanished since it was opened is still recorded, so that
+		// resuming reports it instead of failing the whole snapshot.
+		if size, modTime, hash, err := fingerprint(fsys, entry.Path); err == nil {
+			entry.Size, entry.ModTime, entry.Hash = size, modTime, hash
+		}
+		snapshot.Files = append(snapshot.Files, entry)
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("could not write session %s: %v", path, err)
+	}
+	return nil
+}
+
+// ResumeFileManager restores a manager saved with SaveSession. Files that no
+// longer exist are dropped, and files whose content differs from the snapshot
+// are kept but reported as FileChanged; they keep the fingerprint seen before
+// the save, so edits that expect it are refused as stale. The session file
+// itself is always read from the operating system; options select the
+// backend the files live on and override the saved manager options.
+func ResumeFileManager(path string, options ...ManagerOption) (*FileManager, []RestoredFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read session %s: %v", path, err)
+	}
+	var snapshot session
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, nil, fmt.Errorf("invalid session %s: %v", path, err)
+	}
+
+	if snapshot.WorkingDir == "" {
+		return nil, nil, fmt.Errorf("invalid session %s: no working directory", path)
+	}
+	saved := []ManagerOption{
+		WithAutoFormat(snapshot.AutoFormat),
+		WithMaxLineWidth(snapshot.LineWidth),
+		WithCharBudget(snapshot.CharBudget),
+	}
+	fm := NewFileManager(snapshot.WorkingDir, append(saved, options...)...)
+	if !isDirFS(fm.fs, fm.WorkingDir) {
+		return nil, nil, fmt.Errorf("working directory %s no longer exists", snapshot.WorkingDir)
+	}
+	if snapshot.Overlay != nil {
+		overlay, err := fm.EnableOverlay(snapshot.Overlay.ScratchDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not restore overlay: %v", err)
+		}
+		for _, deleted := range snapshot.Overlay.Deleted {
+			overlay.deleted[deleted] = true
+		}
+	}
+	if snapshot.ID != "" {
+		fm.ID = snapshot.ID
+		registerID(fm.ID)
+	}
+	restored := make([]RestoredFile, 0, len(snapshot.Files))
+	for _, entry := range snapshot.Files {
+		size, modTime, hash, err := fingerprint(fm.fs, entry.Path)
+		if err != nil {
+			restored = append(restored, RestoredFile{Path: entry.Path, Status: FileMissing})
+			continue
+		}
+		status := FileUnchanged
+		if size != entry.Size || !modTime.Equal(entry.ModTime) || hash != entry.Hash {
+			status = FileChanged
+		}
+		file := fm.newFile(entry.Path)
+		file.Workdir = entry.Workdir
+		file.Start, file.End, file.Window = entry.Start, entry.End, entry.Window
+		file.AutoFormat, file.MaxLineWidth, file.CharBudget = entry.AutoFormat, entry.MaxLineWidth, entry.CharBudget
+		if entry.Seen != nil {
+			file.seen.Store(entry.Seen)
+		}
+		fm.Files[entry.Path] = file
+		if entry.Path == snapshot.Recent {
+			fm.Recent = file
+		}
+		restored = append(restored, RestoredFile{Path: entry.Path, Status: status})
+	}
+	return fm, restored, nil
+}
+
+func fingerprint(fsys FS, path string) (size int64, modTime time.Time, hash string, err error) {
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+	if info.IsDir() {
+		return 0, time.Time{}, "", fmt.Errorf("%s is a directory", path)
+	}
+	content, err := fsys.ReadFile(path)
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+	return info.Size(), info.ModTime(), contentHash(content), nil
+}
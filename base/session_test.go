@@ -0,0 +1,129 @@
+package base
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"kept.txt", "changed.txt", "deleted.txt"} {
+		numberedFile(t, filepath.Join(dir, name), 300)
+	}
+	fm := NewFileManager(dir, WithCharBudget(500), WithMaxLineWidth(40), WithAutoFormat(true))
+	kept, err := fm.Open("kept.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept.Scroll(120, ScrollDown)
+	changed, err := fm.Open("changed.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := changed.View(); err != nil {
+		t.Fatal(err)
+	}
+	seen := changed.Fingerprint()
+	if _, err := fm.Open("deleted.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fm.Open("kept.txt"); err != nil {
+		t.Fatal(err)
+	}
+	session := filepath.Join(t.TempDir(), "session.json")
+	if err := fm.SaveSession(session); err != nil {
+		t.Fatalf("SaveSession: %v", err)
+	}
+
+	appendFile(t, filepath.Join(dir, "changed.txt"), "appended\n")
+	if err := os.Remove(filepath.Join(dir, "deleted.txt")); err != nil {
+		t.Fatal(err)
+	}
+	resumed, restored, err := ResumeFileManager(session)
+	if err != nil {
+		t.Fatalf("ResumeFileManager: %v", err)
+	}
+	want := []RestoredFile{
+		{Path: filepath.Join(dir, "changed.txt"), Status: FileChanged},
+		{Path: filepath.Join(dir, "deleted.txt"), Status: FileMissing},
+		{Path: filepath.Join(dir, "kept.txt"), Status: FileUnchanged},
+	}
+	if !slices.Equal(restored, want) {
+		t.Errorf("restored = %v, want %v", restored, want)
+	}
+	if resumed.ID != fm.ID || resumed.Cwd() != dir {
+		t.Errorf("resumed ID %q in %s, want %q in %s", resumed.ID, resumed.Cwd(), fm.ID, dir)
+	}
+	if _, ok := resumed.Files[filepath.Join(dir, "deleted.txt")]; ok {
+		t.Error("a deleted file was restored")
+	}
+
+	file := resumed.RecentFile()
+	if file == nil || file.Path != kept.Path {
+		t.Fatalf("Recent = %v, want %s", file, kept.Path)
+	}
+	if file.Start != kept.Start || file.End != kept.End || file.Window != kept.Window {
+		t.Errorf("window = %d-%d/%d, want %d-%d/%d", file.Start, file.End, file.Window, kept.Start, kept.End, kept.Window)
+	}
+	if file.CharBudget != 500 || file.MaxLineWidth != 40 || !file.AutoFormat {
+		t.Errorf("file settings = %d, %d, %v; want the saved ones", file.CharBudget, file.MaxLineWidth, file.AutoFormat)
+	}
+	if resumed.charBudget != 500 || resumed.lineWidth != 40 || !resumed.autoFormat {
+		t.Errorf("manager options = %d, %d, %v; want the saved ones", resumed.charBudget, resumed.lineWidth, resumed.autoFormat)
+	}
+
+	// The changed file still reports what was seen before the save, so an
+	// edit made against that view is refused.
+	changed = resumed.Files[filepath.Join(dir, "changed.txt")]
+	if got := changed.Fingerprint(); got.Hash != seen.Hash {
+		t.Errorf("resumed fingerprint %s, want %s", got.Hash, seen.Hash)
+	}
+	result := changed.MultiEdit([]LineEdit{{Start: 1, End: 1, Text: "edited", Fingerprint: seen.Hash}}, ScopeFile)
+	if !errors.Is(result.Error, ErrStaleContent) {
+		t.Errorf("edit against the saved fingerprint returned %v, want ErrStaleContent", result.Error)
+	}
+}
+
+func TestSessionOverlay(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.txt"), "a\n")
+	writeFile(t, filepath.Join(dir, "b.txt"), "b\n")
+	session := filepath.Join(t.TempDir(), "session.json")
+
+	fm := NewFileManager(dir)
+	if _, err := fm.EnableOverlay(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.SaveSession(session); err == nil {
+		t.Error("SaveSession saved an in-memory overlay")
+	}
+
+	fm = NewFileManager(dir)
+	if _, err := fm.EnableOverlay(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.FS().WriteFile(filepath.Join(dir, "a.txt"), []byte("A\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.FS().Remove(filepath.Join(dir, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.SaveSession(session); err != nil {
+		t.Fatalf("SaveSession: %v", err)
+	}
+	resumed, _, err := ResumeFileManager(session)
+	if err != nil {
+		t.Fatalf("ResumeFileManager: %v", err)
+	}
+	overlay := resumed.Overlay()
+	if overlay == nil {
+		t.Fatal("the overlay was not restored")
+	}
+	want := []string{"modified a.txt", "deleted b.txt"}
+	if got := changedPaths(t, dir, overlay); !slices.Equal(got, want) {
+		t.Errorf("restored Changes = %q, want %q", got, want)
+	}
+}
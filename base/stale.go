@@ -0,0 +1,106 @@
+package base
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrStaleContent is returned when an edit expects content the file no
+// longer has, usually because something else changed it after it was viewed.
+var ErrStaleContent = errors.New("file changed since it was last viewed")
+
+// Fingerprint identifies the content of a file at one point in time.
+type Fingerprint struct {
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// StaleContentError is returned by edits whose expected fingerprint or text
+// does not match the file. View shows the lines the edit addressed as they
+// are now, and Current is the fingerprint to expect on the next attempt.
+type StaleContentError struct {
+	Path     string
+	Expected string
+	Current  Fingerprint
+	Detail   string
+	View     string
+}
+
+func (e *StaleContentError) Error() string {
+	return fmt.Sprintf("%v: %s (%s)", ErrStaleContent, e.Path, e.Detail)
+}
+
+func (e *StaleContentError) Unwrap() error {
+	return ErrStaleContent
+}
+
+func contentHash(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Fingerprint returns the fingerprint of the file as it was when its window
+// was last read or it was last written through this File. It is the zero
+// Fingerprint before either happened.
+func (f *File) Fingerprint() Fingerprint {
+	if seen := f.seen.Load(); seen != nil {
+		return *seen
+	}
+	return Fingerprint{}
+}
+
+// checkExpected validates the expected fingerprint and text of edits
+// against content, the current text of the file, before they are applied.
+// The caller must hold f.mu.
+func (f *File) checkExpected(edits []LineEdit, content string, current Fingerprint) error {
+	lines, _ := splitLines(content)
+	stale := func(expected string, detail string) error {
+		regions := make([]lineRange, 0, len(edits))
+		for _, edit := range edits {
+			start, end := edit.Start-1, max(edit.End, edit.Start)
+			if edit.Mode == EditInsertAfter {
+				start, end = edit.Start, edit.Start+1
+			}
+			regions = append(regions, lineRange{min(max(start, 0), len(lines)), min(max(end, 0), len(lines))})
+		}
+		slices.SortFunc(regions, func(a, b lineRange) int { return a.start - b.start })
+		// The error carries a fresh view, so the current content counts as
+		// seen for the next attempt.
+		f.seen.Store(&current)
+		return &StaleContentError{
+			Path:     f.Path,
+			Expected: expected,
+			Current:  current,
+			Detail:   detail,
+			View:     numberedRegions(content, regions, editContextLines),
+		}
+	}
+	for _, edit := range edits {
+		if edit.Fingerprint != "" && edit.Fingerprint != current.Hash {
+			return stale(edit.Fingerprint, "its fingerprint is now "+current.Hash)
+		}
+	}
+	for _, edit := range edits {
+		if edit.ExpectedText == "" || (edit.Mode != "" && edit.Mode != EditReplace && edit.Mode != EditDelete) {
+			continue
+		}
+		start, end := edit.Start-1, edit.End
+		if start < 0 || end > len(lines) || start >= end {
+			return stale(edit.ExpectedText, fmt.Sprintf("lines %d-%d no longer exist", edit.Start, edit.End))
+		}
+		// content has its line endings normalized, but text copied from a
+		// CRLF file may still carry them.
+		expected := strings.ReplaceAll(edit.ExpectedText, "\r\n", "\n")
+		actual := joinLines(lines[start:end], false)
+		if actual != joinLines(textLines(expected), false) {
+			return stale(edit.ExpectedText, fmt.Sprintf("lines %d-%d no longer hold the expected text", edit.Start, edit.End))
+		}
+	}
+	return nil
+}
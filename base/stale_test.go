@@ -0,0 +1,33 @@
+package base
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpectedTextFromCRLFFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	writeFile(t, path, "one\r\ntwo\r\nthree\r\n")
+	file, err := NewFileManager(dir).Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := file.MultiEdit([]LineEdit{{Start: 2, End: 3, Text: "TWO\nTHREE", ExpectedText: "two\r\nthree\r\n"}}, ScopeFile)
+	if result.Error != nil {
+		t.Fatalf("edit with CRLF ExpectedText: %v", result.Error)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "one\r\nTWO\r\nTHREE\r\n" {
+		t.Errorf("file = %q", data)
+	}
+	result = file.MultiEdit([]LineEdit{{Start: 1, End: 1, Text: "ONE", ExpectedText: "uno\r\n"}}, ScopeFile)
+	if !errors.Is(result.Error, ErrStaleContent) {
+		t.Errorf("edit with other ExpectedText returned %v, want ErrStaleContent", result.Error)
+	}
+}
@@ -0,0 +1,225 @@
+package base
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type LineEnding string
+
+const (
+	LineEndingNone  LineEnding = "none"
+	LineEndingLF    LineEnding = "lf"
+	LineEndingCRLF  LineEnding = "crlf"
+	LineEndingCR    LineEnding = "cr"
+	LineEndingMixed LineEnding = "mixed"
+)
+
+// FileStat describes a file the way "ls -l", "file" and "wc -l" would.
+// Content fields are only filled in for regular files; Lines and LineEnding
+// are left empty for binary files.
+type FileStat struct {
+	Path    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+	// UID and GID are -1 where the filesystem does not report an owner.
+	UID int
+	GID int
+	// SymlinkTarget is set when Path is a symbolic link. The other fields
+	// describe the file it points to.
+	SymlinkTarget string
+	MIMEType      string
+	Binary        bool
+	Encoding      string
+	Lines         int
+	LineEnding    LineEnding
+	Error         error
+}
+
+// linkFS is implemented by filesystems that can report on symbolic links
+// themselves rather than the files they point to.
+type linkFS interface {
+	Lstat(name string) (fs.FileInfo, error)
+	Readlink(name string) (string, error)
+}
+
+func (OSFS) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (OSFS) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+// Stat describes each of paths, relative to the working directory or
+// absolute. A path that cannot be examined has Error set instead of failing
+// the whole call.
+func (fm *FileManager) Stat(paths ...string) []FileStat {
+	workingDir, fsys := fm.Cwd(), fm.FS()
+	stats := make([]FileStat, 0, len(paths))
+	for _, path := range paths {
+		stat := FileStat{Path: path, UID: -1, GID: -1}
+		absPath, err := resolvePath(workingDir, path)
+		if err != nil {
+			stat.Error = fmt.Errorf("invalid path: %v", err)
+		} else {
+			stat.Error = statFile(fsys, absPath, &stat)
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+func statFile(fsys FS, path string, stat *FileStat) error {
+	// Archive members only exist through ArchiveFS; everything else is
+	// described as it is on disk, so a .gz file is not decompressed.
+	if _, _, member := splitArchivePath(path); !member {
+		fsys = unwrapArchiveFS(fsys)
+	}
+	if links, ok := fsys.(linkFS); ok {
+		info, err := links.Lstat(path)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if stat.SymlinkTarget, err = links.Readlink(path); err != nil {
+				return err
+			}
+		}
+	}
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return err
+	}
+	stat.Size, stat.Mode, stat.ModTime = info.Size(), info.Mode(), info.ModTime()
+	if uid, gid, ok := fileOwner(info); ok {
+		stat.UID, stat.GID = uid, gid
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := fsys.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReaderSize(file, binarySniffLen)
+	sniff, err := reader.Peek(binarySniffLen)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return err
+	}
+	if int64(len(sniff)) < info.Size() {
+		sniff = trimPartialRune(sniff)
+	}
+	stat.MIMEType = mimeType(path, sniff)
+	name, enc, bom := detectEncoding(sniff)
+	stat.Encoding = name
+	if name == EncodingBinary {
+		stat.Binary = true
+		return nil
+	}
+	if mediaType, params, err := mime.ParseMediaType(stat.MIMEType); err == nil && strings.HasPrefix(mediaType, "text/") {
+		params["charset"] = name
+		stat.MIMEType = mime.FormatMediaType(mediaType, params)
+	}
+
+	if _, err := reader.Discard(len(bom)); err != nil {
+		return err
+	}
+	var text io.Reader = reader
+	if enc != nil {
+		text = enc.NewDecoder().Reader(reader)
+	}
+	var counter lineCounter
+	if _, err := io.Copy(&counter, text); err != nil {
+		return err
+	}
+	stat.Lines, stat.LineEnding = counter.result()
+	return nil
+}
+
+// mimeType combines the content type sniffed from the first bytes with the
+// one the extension implies: the extension only refines the generic text and
+// binary types, since the content is the better witness otherwise.
+func mimeType(path string, sniff []byte) string {
+	sniffed := http.DetectContentType(sniff)
+	byExtension := mime.TypeByExtension(filepath.Ext(path))
+	if byExtension == "" {
+		return sniffed
+	}
+	if strings.HasPrefix(sniffed, "text/plain") || sniffed == "application/octet-stream" {
+		return byExtension
+	}
+	return sniffed
+}
+
+// lineCounter counts the lines and line endings of text written to it in
+// chunks of any size.
+type lineCounter struct {
+	lf, crlf, cr int
+	// pendingCR is a "\r" at the end of the last chunk, which may be the
+	// start of a "\r\n".
+	pendingCR bool
+	last      byte
+	written   bool
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		c.written = true
+	}
+	for _, b := range p {
+		if c.pendingCR {
+			c.pendingCR = false
+			if b == '\n' {
+				c.crlf++
+				c.last = b
+				continue
+			}
+			c.cr++
+		}
+		switch b {
+		case '\r':
+			c.pendingCR = true
+		case '\n':
+			c.lf++
+		}
+		c.last = b
+	}
+	return len(p), nil
+}
+
+// result returns the number of lines, counting an unterminated last line, and
+// the line endings used.
+func (c *lineCounter) result() (int, LineEnding) {
+	if c.pendingCR {
+		c.pendingCR = false
+		c.cr++
+	}
+	lines := c.lf + c.crlf + c.cr
+	if c.written && c.last != '\n' && c.last != '\r' {
+		lines++
+	}
+	switch {
+	case c.lf+c.crlf+c.cr == 0:
+		return lines, LineEndingNone
+	case c.lf == 0 && c.cr == 0:
+		return lines, LineEndingCRLF
+	case c.crlf == 0 && c.cr == 0:
+		return lines, LineEndingLF
+	case c.crlf == 0 && c.lf == 0:
+		return lines, LineEndingCR
+	}
+	return lines, LineEndingMixed
+}
@@ -0,0 +1,71 @@
+package base
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStat(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "lf.json"), "{\n\"a\": 1\n}")
+	writeFile(t, filepath.Join(dir, "crlf.txt"), "x\r\ny\r\n")
+	writeFile(t, filepath.Join(dir, "mixed.txt"), "x\r\ny\nz")
+	writeFile(t, filepath.Join(dir, "cr.txt"), "x\ry\r")
+	writeFile(t, filepath.Join(dir, "utf16.txt"), "\xff\xfea\x00\r\x00\n\x00b\x00")
+	writeFile(t, filepath.Join(dir, "corrupt.gz"), "not gzip")
+	// The "é" straddles the end of the sample encodings are detected from.
+	writeFile(t, filepath.Join(dir, "boundary.txt"), strings.Repeat("a", binarySniffLen-1)+"é\nmore\n")
+	gz := gzipped(t, "one\ntwo\n")
+	if err := os.WriteFile(filepath.Join(dir, "log.gz"), gz, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("crlf.txt", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		lines    int
+		ending   LineEnding
+		encoding string
+		mime     string
+		binary   bool
+		size     int64
+	}{
+		{"lf.json", 3, LineEndingLF, EncodingUTF8, "application/json", false, 10},
+		{"crlf.txt", 2, LineEndingCRLF, EncodingUTF8, "text/plain; charset=utf-8", false, 6},
+		{"mixed.txt", 3, LineEndingMixed, EncodingUTF8, "text/plain; charset=utf-8", false, 6},
+		{"cr.txt", 2, LineEndingCR, EncodingUTF8, "text/plain; charset=utf-8", false, 4},
+		{"utf16.txt", 2, LineEndingCRLF, EncodingUTF16LE, "text/plain; charset=utf-16le", false, 10},
+		{"log.gz", 0, "", EncodingBinary, "application/x-gzip", true, int64(len(gz))},
+		{"corrupt.gz", 1, LineEndingNone, EncodingUTF8, "application/gzip", false, 8},
+		{"boundary.txt", 2, LineEndingLF, EncodingUTF8, "text/plain; charset=utf-8", false, binarySniffLen + 7},
+		{"link", 2, LineEndingCRLF, EncodingUTF8, "text/plain; charset=utf-8", false, 6},
+	}
+	paths := make([]string, 0, len(tests))
+	for _, test := range tests {
+		paths = append(paths, test.path)
+	}
+	stats := NewFileManager(dir).Stat(paths...)
+	for i, test := range tests {
+		stat := stats[i]
+		if stat.Error != nil {
+			t.Errorf("%s: %v", test.path, stat.Error)
+			continue
+		}
+		if stat.Lines != test.lines || stat.LineEnding != test.ending || stat.Encoding != test.encoding ||
+			stat.MIMEType != test.mime || stat.Binary != test.binary || stat.Size != test.size {
+			t.Errorf("%s: got %d lines, %q, %s, %q, binary %v, %d bytes; want %d lines, %q, %s, %q, binary %v, %d bytes",
+				test.path, stat.Lines, stat.LineEnding, stat.Encoding, stat.MIMEType, stat.Binary, stat.Size,
+				test.lines, test.ending, test.encoding, test.mime, test.binary, test.size)
+		}
+	}
+	if stats[len(stats)-1].SymlinkTarget != "crlf.txt" {
+		t.Errorf("link: symlink target %q, want %q", stats[len(stats)-1].SymlinkTarget, "crlf.txt")
+	}
+	if missing := NewFileManager(dir).Stat("missing")[0]; missing.Error == nil {
+		t.Error("missing file: no error")
+	}
+}
@@ -0,0 +1,251 @@
+package base
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// tailChunk is how much Tail reads at a time, walking back from the end.
+const tailChunk = 8192
+
+// TailResult holds lines read from the end of a file by Tail or Follow.
+type TailResult struct {
+	Lines []string
+	// Offset is the byte offset Follow continues from.
+	Offset int64
+	// Rotated reports that the file was truncated or replaced since the
+	// previous read, so Follow started again from its beginning.
+	Rotated bool
+	// More reports that the character budget stopped Follow before the end
+	// of the file; the next call returns the rest. From Tail, it reports
+	// that the budget left out some of the lines asked for, or cut the last.
+	More bool
+}
+
+type followState struct {
+	offset int64
+	info   fs.FileInfo
+}
+
+// Tail returns the last n lines of the file, found by reading backwards
+// from its end rather than scanning it, and makes Follow continue from the
+// end of the file. Only UTF-8 text can be split into lines from the middle
+// this way; other files are refused, binary ones with ErrBinaryFile. Under a CharBudget it returns only the last lines that
+// fit, reading no more than the budget can use; a last line larger than the
+// whole budget is cut to fit it.
+func (f *File) Tail(n int) (TailResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tail(n)
+}
+
+func (f *File) tail(n int) (TailResult, error) {
+	file, info, err := f.openAt()
+	if err != nil {
+		return TailResult{}, err
+	}
+	defer file.Close()
+	if err := f.checkTailable(file, info.Size()); err != nil {
+		return TailResult{}, err
+	}
+	size := info.Size()
+	var data []byte
+	var result TailResult
+	// A trailing newline ends the last line rather than starting another.
+	// At least one line is read to find where the last complete line ends.
+	start := size
+	for start > 0 && bytes.Count(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) < max(n, 1) {
+		// Only as much is read as the character budget can use, at the
+		// widest UTF-8 encoding of a character.
+		if f.CharBudget > 0 && len(data) > f.CharBudget*utf8.UTFMax {
+			result.More = true
+			break
+		}
+		start = max(start-tailChunk, 0)
+		chunk := make([]byte, int(size-start)-len(data))
+		if _, err := file.ReadAt(chunk, start); err != nil && !errors.Is(err, io.EOF) {
+			return TailResult{}, err
+		}
+		data = append(chunk, data...)
+	}
+	// When the loop stopped early, the first line is cut and is dropped here,
+	// unless it is all there is.
+	if start == 0 {
+		data = bytes.TrimPrefix(data, utf8BOM)
+	}
+	lines := f.splitLog(data)
+	if start > 0 && len(lines) > 1 && (n < 0 || len(lines) <= n) {
+		lines = lines[1:]
+	}
+	if n >= 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	if f.CharBudget > 0 {
+		chars := 0
+		for i := len(lines) - 1; i >= 0; i-- {
+			if chars += utf8.RuneCountInString(lines[i]); chars > f.CharBudget {
+				result.More = true
+				if i == len(lines)-1 {
+					lines[i] = truncateLine(lines[i], f.CharBudget)
+				} else {
+					lines = lines[i+1:]
+				}
+				break
+			}
+		}
+	}
+	// Follow picks up after the last complete line, so a line still being
+	// written is returned whole once it is finished.
+	result.Lines = lines
+	result.Offset = size - int64(len(data)) + int64(bytes.LastIndexByte(data, '\n')+1)
+	f.follow = &followState{offset: result.Offset, info: info}
+	return result, nil
+}
+
+// Follow returns the complete lines appended to the file since the previous
+// Tail or Follow, tracking the byte offset it read up to. A line still being
+// written is left for the next call. If the file shrank or was replaced, as
+// log rotation does, it is read again from the beginning. Before any Tail
+// or Follow, it returns the last initial lines like Tail.
+func (f *File) Follow(initial int) (TailResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.follow == nil {
+		return f.tail(initial)
+	}
+	file, info, err := f.openAt()
+	if err != nil {
+		return TailResult{}, err
+	}
+	defer file.Close()
+	if err := f.checkTailable(file, info.Size()); err != nil {
+		return TailResult{}, err
+	}
+
+	result := TailResult{Offset: f.follow.offset}
+	if info.Size() < f.follow.offset || !sameFile(f.follow.info, info) {
+		result.Rotated, result.Offset = true, 0
+	}
+	// Only as much is read as the character budget can use, at the widest
+	// UTF-8 encoding of a character.
+	size := info.Size() - result.Offset
+	if f.CharBudget > 0 && size > int64(f.CharBudget)*utf8.UTFMax {
+		size, result.More = int64(f.CharBudget)*utf8.UTFMax, true
+	}
+	data := make([]byte, size)
+	if _, err := file.ReadAt(data, result.Offset); err != nil && !errors.Is(err, io.EOF) {
+		return TailResult{}, err
+	}
+	// A read cut short may end inside a character.
+	for i := 1; result.More && i < utf8.UTFMax && i <= len(data); i++ {
+		if start := len(data) - i; utf8.RuneStart(data[start]) {
+			if !utf8.FullRune(data[start:]) {
+				data = data[:start]
+			}
+			break
+		}
+	}
+	if f.CharBudget > 0 && utf8.RuneCount(data) > f.CharBudget {
+		data, result.More = data[:runeOffset(data, f.CharBudget)], true
+	}
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	if complete == 0 && result.More {
+		// A single line longer than the budget is returned cut rather than
+		// never.
+		complete = len(data)
+	}
+	if result.Offset == 0 {
+		result.Lines = f.splitLog(bytes.TrimPrefix(data[:complete], utf8BOM))
+	} else {
+		result.Lines = f.splitLog(data[:complete])
+	}
+	result.Offset += int64(complete)
+	f.follow = &followState{offset: result.Offset, info: info}
+	return result, nil
+}
+
+// checkTailable fails unless the file of size bytes open in file is UTF-8
+// text, judged from its first bytes: Tail and Follow split the raw data at
+// newline bytes and read it as UTF-8.
+func (f *File) checkTailable(file io.ReaderAt, size int64) error {
+	head := make([]byte, min(size, binarySniffLen))
+	n, err := file.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	head = head[:n]
+	if int64(n) < size {
+		head = trimPartialRune(head)
+	}
+	switch name, _, _ := detectEncoding(head); name {
+	case EncodingUTF8:
+		return nil
+	case EncodingBinary:
+		return fmt.Errorf("%w: %s cannot be tailed as text", ErrBinaryFile, f.Path)
+	default:
+		return fmt.Errorf("%s is %s text; Tail and Follow only read UTF-8", f.Path, name)
+	}
+}
+
+// runeOffset returns the byte offset of the n-th character of data.
+func runeOffset(data []byte, n int) int {
+	offset := 0
+	for ; n > 0 && offset < len(data); n-- {
+		_, size := utf8.DecodeRune(data[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// splitLog splits log data into display lines.
+func (f *File) splitLog(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = truncateLine(strings.TrimSuffix(line, "\r"), f.lineWidth())
+	}
+	return lines
+}
+
+type readerAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// openAt opens the file for random access.
+func (f *File) openAt() (readerAtCloser, fs.FileInfo, error) {
+	file, err := f.filesystem().Open(f.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	readerAt, ok := file.(readerAtCloser)
+	if !ok {
+		_ = file.Close()
+		return nil, nil, errors.New("the filesystem does not support random access")
+	}
+	return readerAt, info, nil
+}
+
+// sameFile reports whether two stats describe the same file. Only the
+// operating system can tell files apart by identity; for other filesystems
+// the file is assumed to be the same.
+func sameFile(a fs.FileInfo, b fs.FileInfo) bool {
+	if a.Sys() == nil || b.Sys() == nil {
+		return true
+	}
+	return os.SameFile(a, b)
+}
@@ -0,0 +1,150 @@
+package base
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func appendFile(t *testing.T, path string, text string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollowCompletesPartialLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeFile(t, path, "a\nb\npartial")
+	file, err := NewFileManager(dir).Open("app.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := file.Tail(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "partial"}; !slices.Equal(result.Lines, want) {
+		t.Errorf("Tail(2) = %q, want %q", result.Lines, want)
+	}
+	appendFile(t, path, "-rest\nc\n")
+	result, err = file.Follow(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"partial-rest", "c"}; !slices.Equal(result.Lines, want) {
+		t.Errorf("Follow = %q, want %q", result.Lines, want)
+	}
+}
+
+func TestFollowBudgetCountsCharacters(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeFile(t, path, "")
+	file, err := NewFileManager(dir, WithCharBudget(10)).Open("app.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Tail(1); err != nil {
+		t.Fatal(err)
+	}
+	// Each line is 4 characters but 9 bytes.
+	appendFile(t, path, strings.Repeat("äöü\n", 100))
+	var lines []string
+	for {
+		result, err := file.Follow(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Lines) != 2 && result.More {
+			t.Fatalf("Follow under a 10 character budget returned %d lines", len(result.Lines))
+		}
+		lines = append(lines, result.Lines...)
+		if !result.More {
+			break
+		}
+	}
+	if len(lines) != 100 || lines[99] != "äöü" {
+		t.Errorf("followed %d lines ending in %q, want 100 lines of %q", len(lines), lines[len(lines)-1], "äöü")
+	}
+}
+
+func TestTailBudget(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	numberedFile(t, path, 100)
+	file, err := NewFileManager(dir, WithCharBudget(20)).Open("app.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := file.Tail(50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"value 98", "value 99"}; !slices.Equal(result.Lines, want) || !result.More {
+		t.Errorf("Tail under a 20 character budget = %q, %v; want %q and More", result.Lines, result.More, want)
+	}
+
+	appendFile(t, path, strings.Repeat("x", 100000)+"\n")
+	result, err = file.Tail(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Lines) != 1 || !strings.HasPrefix(result.Lines[0], strings.Repeat("x", 20)+" … [line truncated") || !result.More {
+		t.Errorf("Tail of a long last line = %q, %v; want it cut to the budget", result.Lines, result.More)
+	}
+	if info, _ := os.Stat(path); result.Offset != info.Size() {
+		t.Errorf("Offset = %d, want the end of the file at %d", result.Offset, info.Size())
+	}
+}
+
+func TestTailEncoding(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "bom.log"), "\xEF\xBB\xBFfirst\nsecond\n")
+	writeFile(t, filepath.Join(dir, "latin1.log"), "caf\xe9\nna\xefve\n")
+	writeFile(t, filepath.Join(dir, "utf16.log"), "\xFF\xFEa\x00\n\x00")
+	var binary strings.Builder
+	for b := range 256 {
+		binary.WriteByte(byte(b))
+	}
+	writeFile(t, filepath.Join(dir, "binary.log"), binary.String())
+	fm := NewFileManager(dir)
+
+	file, err := fm.Open("bom.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := file.Tail(5)
+	if want := []string{"first", "second"}; err != nil || !slices.Equal(result.Lines, want) {
+		t.Errorf("Tail of a file with a BOM = %q, %v; want %q", result.Lines, err, want)
+	}
+
+	for _, name := range []string{"latin1.log", "utf16.log", "binary.log"} {
+		file, err := fm.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Tail(5); err == nil {
+			t.Errorf("Tail of %s succeeded", name)
+		}
+		if _, err := file.Follow(5); err == nil {
+			t.Errorf("Follow of %s succeeded", name)
+		}
+	}
+	file, err = fm.Open("binary.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Tail(5); !errors.Is(err, ErrBinaryFile) {
+		t.Errorf("Tail of a binary file returned %v, want ErrBinaryFile", err)
+	}
+}
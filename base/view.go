@@ -0,0 +1,121 @@
+package base
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// CharsPerToken is the rough number of characters in a model token, used to
+// turn token budgets into character budgets.
+const CharsPerToken = 4
+
+// View is the part of a file that Read showed.
+type View struct {
+	// Lines holds the lines shown by 1-based line number, or for binary files
+	// the rows of the hex dump by byte offset.
+	Lines map[int]string
+	// Start and End are the window that was actually shown, as a [start, end)
+	// range of 0-based lines (rows for binary files). End is where the next
+	// window should start; it is before the end of the requested window when
+	// the character budget ran out.
+	Start int
+	End   int
+	// Chars is how much of the character budget the view used: the
+	// characters shown, counting the markers of lines cut at MaxLineWidth.
+	// A first line cut to fit the budget counts as the whole budget.
+	Chars int
+	// Truncated reports that the budget ended the window early or cut its
+	// only line.
+	Truncated bool
+	Binary    bool
+}
+
+// SetTokenBudget sets the character budget of the window to roughly tokens
+// model tokens.
+func (f *File) SetTokenBudget(tokens int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.CharBudget = tokens * CharsPerToken
+}
+
+// View reads the current window like Read, and reports where it ended.
+// Lines are added until the window or the character budget is used up. A
+// first line larger than the whole budget is cut to fit it, so the view
+// always makes progress.
+func (f *File) View() (View, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.view(f.Start, f.End, f.CharBudget)
+}
+
+// ReadRange reads lines [start, end), 0-based, like View but without moving
+// the window, under a budget of chars characters (0 for none).
+func (f *File) ReadRange(start int, end int, chars int) (View, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.view(start, end, chars)
+}
+
+// fit accounts for line in a view with a budget of chars characters (0 for
+// none). It returns false once the budget is used up, except for the first
+// line, which is cut to fit instead.
+func (v *View) fit(line string, budget int) (string, bool) {
+	chars := utf8.RuneCountInString(line)
+	if budget <= 0 || v.Chars+chars <= budget {
+		v.Chars += chars
+		return line, true
+	}
+	v.Truncated = true
+	if len(v.Lines) > 0 {
+		return "", false
+	}
+	v.Chars = budget
+	return truncateLine(line, budget), true
+}
+
+func (f *File) view(start int, end int, budget int) (View, error) {
+	start = max(start, 0)
+	view := View{Lines: make(map[int]string), Start: start, End: start}
+
+	ix, err := f.loadIndex()
+	if err != nil {
+		return view, err
+	}
+	if ix.format.encoding == EncodingBinary {
+		view.Binary = true
+		rows, err := f.readHex(start, end)
+		if err != nil {
+			return view, err
+		}
+		offsets := make([]int, 0, len(rows))
+		for offset := range rows {
+			offsets = append(offsets, offset)
+		}
+		sort.Ints(offsets)
+		for _, offset := range offsets {
+			row, ok := view.fit(rows[offset], budget)
+			if !ok {
+				break
+			}
+			view.Lines[offset] = row
+			view.End++
+		}
+		return view, nil
+	}
+
+	f.seen.Store(&ix.format.fingerprint)
+	lines, err := f.readLines(ix, start, end)
+	if err != nil {
+		return view, err
+	}
+	width := f.lineWidth()
+	for i, line := range lines {
+		line, ok := view.fit(truncateLine(line, width), budget)
+		if !ok {
+			break
+		}
+		view.Lines[start+i+1] = line
+		view.End++
+	}
+	return view, nil
+}
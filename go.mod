@@ -2,7 +2,11 @@ module github.com/lighmon-even/filetool
 
 go 1.22.5
 
-require github.com/kaptinlin/jsonschema v0.2.1
+require (
+	github.com/kaptinlin/jsonschema v0.2.1
+	golang.org/x/text v0.16.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/bahlo/generic-list-go v0.2.0 // indirect
@@ -19,7 +23,5 @@ require (
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/wk8/go-ordered-map/v2 v2.1.8 // indirect
 	golang.org/x/sys v0.21.0 // indirect
-	golang.org/x/text v0.16.0 // indirect
 	golang.org/x/xerrors v0.0.0-20231012003039-104605ab7028 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -10,6 +10,14 @@ var (
 	ChangeWorkingDirectory = actions.NewChangeWorkingDirectory()
 	CreateFile             = actions.NewCreateFile()
 	EditFile               = actions.NewEditFile()
+	MultiEditFile          = actions.NewMultiEditFile()
+	OpenFile               = actions.NewOpenFile()
+	SearchFile             = actions.NewSearchFile()
+	BatchRead              = actions.NewBatchRead()
+	TailFile               = actions.NewTailFile()
+	ListFiles              = actions.NewListFiles()
+	StatFiles              = actions.NewStatFiles()
+	StrReplace             = actions.NewStrReplace()
 )
 
 type FileTool struct {
@@ -21,11 +29,17 @@ type FileTool struct {
 func (ft *FileTool) Actions() ([]Action, error) {
 	//Return the list of actions.
 	return []Action{
-		//OpenFile,
+		OpenFile,
+		SearchFile,
+		BatchRead,
+		TailFile,
 		EditFile,
+		MultiEditFile,
+		StrReplace,
 		CreateFile,
 		//Scroll,
-		//ListFiles,
+		ListFiles,
+		StatFiles,
 		//SearchWord,
 		//FindFile,
 		//Write,